@@ -4,23 +4,310 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// A SecretKeySelector selects a key of a Secret.
+type SecretKeySelector struct {
+	// Name of the secret.
+	Name string `json:"name"`
+
+	// Namespace of the secret.
+	Namespace string `json:"namespace"`
+
+	// Key within the secret.
+	Key string `json:"key"`
+}
+
+// A ConfigMapKeySelector selects a key of a ConfigMap.
+type ConfigMapKeySelector struct {
+	// Name of the configmap.
+	Name string `json:"name"`
+
+	// Namespace of the configmap.
+	Namespace string `json:"namespace"`
+
+	// Key within the configmap.
+	Key string `json:"key"`
+}
+
+// A SigningSpec configures the signature of the notifications payload.
+type SigningSpec struct {
+	// SecretKeyRef selects the HMAC-SHA256 signing key.
+	SecretKeyRef SecretKeySelector `json:"secretKeyRef"`
+}
+
+// A BasicAuthSpec configures the HTTP basic authentication.
+type BasicAuthSpec struct {
+	// UsernameSecretRef selects the username.
+	UsernameSecretRef SecretKeySelector `json:"usernameSecretRef"`
+
+	// PasswordSecretRef selects the password.
+	PasswordSecretRef SecretKeySelector `json:"passwordSecretRef"`
+}
+
+// A HeaderSpec is an HTTP header whose value is read from a Secret.
+type HeaderSpec struct {
+	// Name of the header.
+	Name string `json:"name"`
+
+	// ValueSecretRef selects the header value.
+	ValueSecretRef SecretKeySelector `json:"valueSecretRef"`
+}
+
+// An AuthSpec configures the authentication to the registration endpoint.
+// +kubebuilder:validation:XValidation:rule="!(has(self.bearerTokenSecretRef) && has(self.basic))",message="bearerTokenSecretRef and basic are mutually exclusive"
+type AuthSpec struct {
+	// BearerTokenSecretRef selects the token sent as 'Authorization: Bearer <token>'.
+	// +optional
+	BearerTokenSecretRef *SecretKeySelector `json:"bearerTokenSecretRef,omitempty"`
+
+	// Basic configures the HTTP basic authentication.
+	// +optional
+	Basic *BasicAuthSpec `json:"basic,omitempty"`
+
+	// Headers are additional headers sent with every notification.
+	// +optional
+	Headers []HeaderSpec `json:"headers,omitempty"`
+}
+
+// A CABundleSource selects the PEM encoded CA bundle either
+// from a ConfigMap or from a Secret.
+// +kubebuilder:validation:XValidation:rule="has(self.configMapKeyRef) != has(self.secretKeyRef)",message="exactly one of configMapKeyRef and secretKeyRef must be set"
+type CABundleSource struct {
+	// ConfigMapKeyRef selects the CA bundle from a ConfigMap.
+	// +optional
+	ConfigMapKeyRef *ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+
+	// SecretKeyRef selects the CA bundle from a Secret.
+	// +optional
+	SecretKeyRef *SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// A ClientCertificateSpec selects the PEM encoded client certificate and key.
+type ClientCertificateSpec struct {
+	// CertSecretRef selects the client certificate (i.e. 'tls.crt').
+	CertSecretRef SecretKeySelector `json:"certSecretRef"`
+
+	// KeySecretRef selects the client private key (i.e. 'tls.key').
+	KeySecretRef SecretKeySelector `json:"keySecretRef"`
+}
+
+// A TLSSpec configures the TLS connections to the registration endpoint.
+type TLSSpec struct {
+	// CA is the bundle used, along with the system ones,
+	// to verify the endpoint certificate.
+	// +optional
+	CA *CABundleSource `json:"ca,omitempty"`
+
+	// ClientCertificate enables the mutual TLS authentication.
+	// +optional
+	ClientCertificate *ClientCertificateSpec `json:"clientCertificate,omitempty"`
+
+	// ServerName overrides the name used to verify the endpoint certificate.
+	// +optional
+	ServerName string `json:"serverName,omitempty"`
+
+	// InsecureSkipVerify disables the endpoint certificate verification.
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// Notification payload formats.
+const (
+	// FormatRaw is the JSON encoded event.
+	FormatRaw = "raw"
+	// FormatCloudEvents is a CloudEvents 1.0 HTTP message holding the event as data.
+	FormatCloudEvents = "cloudevents"
+
+	// CloudEventsModeBinary carries the attributes in 'ce-' headers and the data as body.
+	CloudEventsModeBinary = "binary"
+	// CloudEventsModeStructured carries the attributes and the data in a JSON envelope.
+	CloudEventsModeStructured = "structured"
+)
+
+// A CloudEventsSpec configures the CloudEvents format.
+type CloudEventsSpec struct {
+	// Mode is the CloudEvents HTTP content mode.
+	// +kubebuilder:validation:Enum=binary;structured
+	// +kubebuilder:default=binary
+	// +optional
+	Mode string `json:"mode,omitempty"`
+}
+
+// Composition id policies.
+const (
+	// CompositionIDRequired delivers only the events with a composition id.
+	CompositionIDRequired = "require"
+	// CompositionIDOptional delivers the events with or without a composition id.
+	CompositionIDOptional = "optional"
+	// CompositionIDOnlyUncorrelated delivers only the events without a composition id.
+	CompositionIDOnlyUncorrelated = "only-uncorrelated"
+)
+
+// A PayloadTemplate renders the notification payload.
+type PayloadTemplate struct {
+	// Body is a Go text/template rendered against the '.Event', its
+	// '.CompositionID' and the '.InvolvedObject' metadata.
+	Body string `json:"body"`
+
+	// ContentType of the rendered payload.
+	// +kubebuilder:default="application/json"
+	// +optional
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// A RegistrationFilter restricts the events delivered to a Registration.
+// All the specified criteria must match, an empty criterion matches everything.
+type RegistrationFilter struct {
+	// Types are the accepted event types (i.e. Normal, Warning).
+	// +optional
+	Types []string `json:"types,omitempty"`
+
+	// Reasons are the accepted event reasons.
+	// +optional
+	Reasons []string `json:"reasons,omitempty"`
+
+	// ExcludedReasons are the rejected event reasons.
+	// +optional
+	ExcludedReasons []string `json:"excludedReasons,omitempty"`
+
+	// Kinds are the accepted involved object kinds.
+	// +optional
+	Kinds []string `json:"kinds,omitempty"`
+
+	// APIGroups are the accepted involved object API groups,
+	// glob patterns are allowed (i.e. '*.crossplane.io').
+	// +optional
+	APIGroups []string `json:"apiGroups,omitempty"`
+
+	// Namespaces are the accepted event namespaces.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// NamespaceSelector selects the event namespaces by label.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// ObjectSelector selects the involved objects by label.
+	// +optional
+	ObjectSelector *metav1.LabelSelector `json:"objectSelector,omitempty"`
+}
+
 // A RegistrationSpec defines the desired state of a Registration.
 type RegistrationSpec struct {
 	ServiceName string `json:"serviceName"`
 	Endpoint    string `json:"endpoint"`
+
+	// CompositionIDPolicy selects the delivered events by whether a composition id
+	// has been found for them: 'require' (the default) delivers only the correlated
+	// ones, 'optional' all of them and 'only-uncorrelated' only the uncorrelated ones.
+	// +kubebuilder:validation:Enum=require;optional;only-uncorrelated
+	// +kubebuilder:default=require
+	// +optional
+	CompositionIDPolicy string `json:"compositionIdPolicy,omitempty"`
+
+	// Filter restricts the events delivered to this registration,
+	// when omitted all the events are delivered.
+	// +optional
+	Filter *RegistrationFilter `json:"filter,omitempty"`
+
+	// FilterExpression is a CEL expression evaluated against the 'event'
+	// and its 'compositionId', only events for which it returns true are
+	// delivered (i.e. 'event.count > 3 && event.reason.startsWith("Cannot")').
+	// +optional
+	FilterExpression string `json:"filterExpression,omitempty"`
+
+	// Signing enables the HMAC signature of the notifications payload,
+	// sent along with its timestamp in the 'X-Eventrouter-Signature'
+	// and 'X-Eventrouter-Timestamp' headers.
+	// +optional
+	Signing *SigningSpec `json:"signing,omitempty"`
+
+	// Auth configures the authentication to the endpoint.
+	// +optional
+	Auth *AuthSpec `json:"auth,omitempty"`
+
+	// TLS configures the connections to the endpoint.
+	// +optional
+	TLS *TLSSpec `json:"tls,omitempty"`
+
+	// Format of the notification payload, defaults to 'raw'.
+	// +kubebuilder:validation:Enum=raw;cloudevents
+	// +optional
+	Format string `json:"format,omitempty"`
+
+	// CloudEvents configures the 'cloudevents' format.
+	// +optional
+	CloudEvents *CloudEventsSpec `json:"cloudEvents,omitempty"`
+
+	// Template renders a custom payload instead of the JSON encoded event,
+	// with the 'cloudevents' format it renders the event data.
+	// +optional
+	Template *PayloadTemplate `json:"template,omitempty"`
+}
+
+// Registration condition types and reasons.
+const (
+	// TypeReady indicates whether the registration can receive events.
+	TypeReady = "Ready"
+	// TypeDegraded indicates whether the deliveries to the registration endpoint are failing.
+	TypeDegraded = "Degraded"
+
+	ReasonAvailable               = "Available"
+	ReasonInvalidFilterExpression = "InvalidFilterExpression"
+	ReasonInvalidTemplate         = "InvalidTemplate"
+	ReasonDeliverySucceeded       = "DeliverySucceeded"
+	ReasonDeliveryFailed          = "DeliveryFailed"
+)
+
+// A RegistrationStatus represents the observed state of a Registration.
+type RegistrationStatus struct {
+	// Conditions of the registration.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// LastDeliveryTime is the time of the last successful delivery.
+	// +optional
+	LastDeliveryTime *metav1.Time `json:"lastDeliveryTime,omitempty"`
+
+	// LastFailureTime is the time of the last failed delivery.
+	// +optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+
+	// LastError is the error of the last failed delivery.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// ConsecutiveFailures is the number of failed deliveries since the last successful one.
+	// +optional
+	ConsecutiveFailures int64 `json:"consecutiveFailures"`
+
+	// Delivered is the number of successful deliveries.
+	// +optional
+	Delivered int64 `json:"delivered"`
+
+	// Failed is the number of failed deliveries.
+	// +optional
+	Failed int64 `json:"failed"`
 }
 
 // +kubebuilder:object:root=true
 
 // A Registration registers a new eventrouter registration.
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="DEGRADED",type="string",JSONPath=".status.conditions[?(@.type=='Degraded')].status"
+// +kubebuilder:printcolumn:name="DELIVERED",type="integer",JSONPath=".status.delivered"
+// +kubebuilder:printcolumn:name="FAILED",type="integer",JSONPath=".status.failed"
+// +kubebuilder:printcolumn:name="LAST DELIVERY",type="date",JSONPath=".status.lastDeliveryTime"
 // +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:resource:scope=Cluster
 type Registration struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec RegistrationSpec `json:"spec"`
+	Spec   RegistrationSpec   `json:"spec"`
+	Status RegistrationStatus `json:"status,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -0,0 +1,140 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is a notification that could not be delivered
+// after all the retry attempts have been exhausted.
+type Entry struct {
+	Time         time.Time       `json:"time"`
+	Registration string          `json:"registration"`
+	Endpoint     string          `json:"endpoint"`
+	Attempts     int             `json:"attempts"`
+	Reason       string          `json:"reason,omitempty"`
+	StatusCode   int             `json:"statusCode,omitempty"`
+	Error        string          `json:"error"`
+	ContentType  string          `json:"contentType,omitempty"`
+	Payload      json.RawMessage `json:"payload,omitempty"`
+}
+
+// RawPayload returns the payload as is when it's valid JSON,
+// otherwise it's encoded as a JSON string.
+func RawPayload(dat []byte) json.RawMessage {
+	if json.Valid(dat) {
+		return dat
+	}
+
+	res, err := json.Marshal(string(dat))
+	if err != nil {
+		return nil
+	}
+	return res
+}
+
+type StoreOpts struct {
+	// Size is the number of entries kept in memory.
+	Size int
+	// Path is the optional file where every entry
+	// is appended as a JSON line.
+	Path string
+}
+
+// NewStore creates a dead-letter store holding the last
+// 'Size' entries in memory and, when 'Path' is set,
+// appending all of them to a file on disk.
+func NewStore(opts StoreOpts) (*Store, error) {
+	size := opts.Size
+	if size <= 0 {
+		size = 1
+	}
+
+	res := &Store{
+		ring: make([]Entry, size),
+	}
+
+	if len(opts.Path) > 0 {
+		fp, err := os.OpenFile(opts.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open dead-letter file '%s': %w", opts.Path, err)
+		}
+		res.file = fp
+	}
+
+	return res, nil
+}
+
+// Store is a bounded in-memory ring of undelivered
+// notifications with an optional on-disk journal.
+type Store struct {
+	mu    sync.Mutex
+	ring  []Entry
+	next  int
+	count int
+	file  *os.File
+}
+
+// Put records the entry overwriting the oldest one
+// when the in-memory ring is full.
+func (s *Store) Put(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ring[s.next] = e
+	s.next = (s.next + 1) % len(s.ring)
+	if s.count < len(s.ring) {
+		s.count++
+	}
+
+	if s.file == nil {
+		return nil
+	}
+
+	dat, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(append(dat, '\n'))
+	return err
+}
+
+// List returns the in-memory entries from the oldest to the newest.
+func (s *Store) List() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]Entry, 0, s.count)
+	start := (s.next - s.count + len(s.ring)) % len(s.ring)
+	for i := 0; i < s.count; i++ {
+		res = append(res, s.ring[(start+i)%len(s.ring)])
+	}
+	return res
+}
+
+// Len returns the number of entries held in memory.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+// Close releases the on-disk journal, if any.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,78 @@
+package deadletter
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreRing(t *testing.T) {
+	s, err := NewStore(StoreOpts{Size: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for _, x := range []string{"a", "b", "c", "d", "e"} {
+		if err := s.Put(Entry{Registration: x}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all := s.List()
+	if len(all) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(all))
+	}
+
+	for i, want := range []string{"c", "d", "e"} {
+		if got := all[i].Registration; got != want {
+			t.Errorf("entry %d: expected %s, got %s", i, want, got)
+		}
+	}
+}
+
+func TestStoreFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deadletter.jsonl")
+
+	s, err := NewStore(StoreOpts{Size: 1, Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, x := range []string{"a", "b"} {
+		err := s.Put(Entry{
+			Registration: x,
+			Payload:      json.RawMessage(`{"reason":"Test"}`),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	fp, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+
+	var lines []Entry
+	sc := bufio.NewScanner(fp)
+	for sc.Scan() {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, e)
+	}
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+
+	if lines[1].Registration != "b" || string(lines[1].Payload) != `{"reason":"Test"}` {
+		t.Errorf("unexpected entry: %+v", lines[1])
+	}
+}
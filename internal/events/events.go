@@ -0,0 +1,125 @@
+// Package events normalizes the core/v1 and the events.k8s.io/v1 Events.
+//
+// The normalized model is the core/v1 Event, so that the notified payloads
+// stay backward compatible, with the events.k8s.io/v1 fields mapped on it
+// (regarding, note, series, reportingController, ...) and the deprecated
+// fields of either API filled from their newer counterparts.
+package events
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// API selects the Events API to watch.
+type API string
+
+const (
+	// APICore watches the core/v1 Events
+	APICore API = "core"
+	// APIEvents watches the events.k8s.io/v1 Events
+	APIEvents API = "events"
+	// APIBoth watches both, the events seen by both are handled once
+	APIBoth API = "both"
+)
+
+// ParseAPI validates the Events API name.
+func ParseAPI(s string) (API, bool) {
+	switch api := API(s); api {
+	case APICore, APIEvents, APIBoth:
+		return api, true
+	case "":
+		return APICore, true
+	}
+	return "", false
+}
+
+// Normalize returns the normalized copy of a core/v1 or
+// events.k8s.io/v1 Event, false for any other object.
+func Normalize(obj interface{}) (*corev1.Event, bool) {
+	switch evt := obj.(type) {
+	case *corev1.Event:
+		res := evt.DeepCopy()
+		normalize(res)
+		return res, true
+	case *eventsv1.Event:
+		res := FromEventsV1(evt)
+		normalize(res)
+		return res, true
+	}
+	return nil, false
+}
+
+// FromEventsV1 maps an events.k8s.io/v1 Event on a core/v1 one,
+// as the API server does when serving it from the core/v1 API.
+func FromEventsV1(evt *eventsv1.Event) *corev1.Event {
+	res := &corev1.Event{
+		ObjectMeta:          *evt.ObjectMeta.DeepCopy(),
+		InvolvedObject:      evt.Regarding,
+		Reason:              evt.Reason,
+		Message:             evt.Note,
+		Type:                evt.Type,
+		Source:              evt.DeprecatedSource,
+		FirstTimestamp:      evt.DeprecatedFirstTimestamp,
+		LastTimestamp:       evt.DeprecatedLastTimestamp,
+		Count:               evt.DeprecatedCount,
+		EventTime:           evt.EventTime,
+		Action:              evt.Action,
+		ReportingController: evt.ReportingController,
+		ReportingInstance:   evt.ReportingInstance,
+	}
+
+	if evt.Related != nil {
+		res.Related = evt.Related.DeepCopy()
+	}
+
+	if evt.Series != nil {
+		res.Series = &corev1.EventSeries{
+			Count:            evt.Series.Count,
+			LastObservedTime: evt.Series.LastObservedTime,
+		}
+	}
+
+	return res
+}
+
+// normalize fills the fields of either API from their counterparts.
+func normalize(evt *corev1.Event) {
+	if len(evt.ReportingController) == 0 {
+		evt.ReportingController = evt.Source.Component
+	}
+	if len(evt.Source.Component) == 0 {
+		evt.Source.Component = evt.ReportingController
+	}
+
+	if len(evt.ReportingInstance) == 0 {
+		evt.ReportingInstance = evt.Source.Host
+	}
+	if len(evt.Source.Host) == 0 {
+		evt.Source.Host = evt.ReportingInstance
+	}
+
+	if s := evt.Series; s != nil && s.Count > evt.Count {
+		evt.Count = s.Count
+	}
+	if evt.Count == 0 {
+		evt.Count = 1
+	}
+
+	if evt.FirstTimestamp.IsZero() && !evt.EventTime.IsZero() {
+		evt.FirstTimestamp = metav1.NewTime(evt.EventTime.Time)
+	}
+
+	if s := evt.Series; s != nil && s.LastObservedTime.After(evt.LastTimestamp.Time) {
+		evt.LastTimestamp = metav1.NewTime(s.LastObservedTime.Time)
+	}
+	if evt.LastTimestamp.IsZero() {
+		evt.LastTimestamp = evt.FirstTimestamp
+	}
+}
+
+// Key identifies an Event revision, the same in both the APIs.
+func Key(evt *corev1.Event) string {
+	return string(evt.UID) + "." + evt.ResourceVersion
+}
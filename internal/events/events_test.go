@@ -0,0 +1,104 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNormalizeEventsV1(t *testing.T) {
+	first := time.Date(2024, 6, 10, 8, 0, 0, 0, time.UTC)
+	last := first.Add(5 * time.Minute)
+
+	evt, ok := Normalize(&eventsv1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test-1-ng.17d7c2f1b1a6d3e0",
+			Namespace:       "default",
+			UID:             "6aa0a50b-1b5b-46e0-b5ec-a1118286f0c4",
+			ResourceVersion: "17223",
+		},
+		EventTime:           metav1.NewMicroTime(first),
+		Series:              &eventsv1.EventSeries{Count: 7, LastObservedTime: metav1.NewMicroTime(last)},
+		ReportingController: "krateo.io/composition-controller",
+		ReportingInstance:   "composition-controller-7d9f",
+		Action:              "Reconcile",
+		Reason:              "CannotCreateExternalResource",
+		Regarding: corev1.ObjectReference{
+			APIVersion: "eks.aws.crossplane.io/v1alpha1",
+			Kind:       "NodeGroup",
+			Name:       "test-1-ng",
+		},
+		Note: "cannot create EKS node group",
+		Type: corev1.EventTypeWarning,
+	})
+	if !ok {
+		t.Fatal("expected events.k8s.io/v1 event to be normalized")
+	}
+
+	if evt.Message != "cannot create EKS node group" || evt.InvolvedObject.Name != "test-1-ng" {
+		t.Errorf("unexpected message or involved object: %+v", evt)
+	}
+
+	if evt.Count != 7 || evt.Series == nil || evt.Series.Count != 7 {
+		t.Errorf("expected series count 7, got count %d, series %+v", evt.Count, evt.Series)
+	}
+
+	if evt.Source.Component != "krateo.io/composition-controller" || evt.Source.Host != "composition-controller-7d9f" {
+		t.Errorf("expected source from reporting controller, got %+v", evt.Source)
+	}
+
+	if !evt.FirstTimestamp.Time.Equal(first) || !evt.LastTimestamp.Time.Equal(last) {
+		t.Errorf("unexpected timestamps: first %v, last %v", evt.FirstTimestamp, evt.LastTimestamp)
+	}
+
+	if got := Key(evt); got != "6aa0a50b-1b5b-46e0-b5ec-a1118286f0c4.17223" {
+		t.Errorf("unexpected key: %s", got)
+	}
+}
+
+func TestNormalizeCoreV1(t *testing.T) {
+	orig := &corev1.Event{
+		Source: corev1.EventSource{Component: "kubelet", Host: "node-1"},
+	}
+
+	evt, ok := Normalize(orig)
+	if !ok {
+		t.Fatal("expected core/v1 event to be normalized")
+	}
+
+	if evt.ReportingController != "kubelet" || evt.ReportingInstance != "node-1" || evt.Count != 1 {
+		t.Errorf("unexpected normalized event: %+v", evt)
+	}
+
+	if len(orig.ReportingController) > 0 {
+		t.Error("expected the original event not to be modified")
+	}
+
+	if _, ok := Normalize(&corev1.Pod{}); ok {
+		t.Error("expected other objects not to be normalized")
+	}
+}
+
+func TestParseAPI(t *testing.T) {
+	table := []struct {
+		in   string
+		want API
+		ok   bool
+	}{
+		{"", APICore, true},
+		{"core", APICore, true},
+		{"events", APIEvents, true},
+		{"both", APIBoth, true},
+		{"all", "", false},
+	}
+
+	for _, tc := range table {
+		got, ok := ParseAPI(tc.in)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%q: expected (%q, %v), got (%q, %v)", tc.in, tc.want, tc.ok, got, ok)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+// coreFields are the field selector keys supported by the core/v1 Events.
+var coreFields = map[string]bool{
+	"metadata.name":                  true,
+	"metadata.namespace":             true,
+	"involvedObject.kind":            true,
+	"involvedObject.namespace":       true,
+	"involvedObject.name":            true,
+	"involvedObject.uid":             true,
+	"involvedObject.apiVersion":      true,
+	"involvedObject.resourceVersion": true,
+	"involvedObject.fieldPath":       true,
+	"reason":                         true,
+	"reportingComponent":             true,
+	"source":                         true,
+	"type":                           true,
+}
+
+// ParseFieldSelector parses a field selector of core/v1 Events keys.
+func ParseFieldSelector(s string) (fields.Selector, error) {
+	sel, err := fields.ParseSelector(s)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, req := range sel.Requirements() {
+		if !coreFields[req.Field] {
+			return nil, fmt.Errorf("field selector key '%s' not supported by the events", req.Field)
+		}
+	}
+	return sel, nil
+}
+
+// FieldSelector translates a field selector of core/v1 Events keys
+// for the watched API; the events.k8s.io/v1 keys are 'regarding.*'
+// in place of 'involvedObject.*' and 'reportingController' in
+// place of both 'source' and 'reportingComponent'.
+func FieldSelector(sel fields.Selector, api API) (fields.Selector, error) {
+	if sel == nil {
+		return fields.Everything(), nil
+	}
+
+	if api != APIEvents {
+		return sel, nil
+	}
+
+	return sel.Transform(func(field, value string) (string, string, error) {
+		switch {
+		case strings.HasPrefix(field, "involvedObject."):
+			return "regarding." + strings.TrimPrefix(field, "involvedObject."), value, nil
+		case field == "source", field == "reportingComponent":
+			return "reportingController", value, nil
+		}
+		return field, value, nil
+	})
+}
@@ -0,0 +1,42 @@
+package events
+
+import "testing"
+
+func TestFieldSelector(t *testing.T) {
+	table := []struct {
+		in     string
+		api    API
+		want   string
+		failed bool
+	}{
+		{in: "", api: APIEvents, want: ""},
+		{in: "type=Warning", api: APICore, want: "type=Warning"},
+		{in: "involvedObject.kind=Pod,source=kubelet", api: APICore, want: "involvedObject.kind=Pod,source=kubelet"},
+		{in: "involvedObject.kind=Pod,reason!=Pulled", api: APIEvents, want: "regarding.kind=Pod,reason!=Pulled"},
+		{in: "source=kubelet", api: APIEvents, want: "reportingController=kubelet"},
+		{in: "reportingComponent=kubelet,type=Normal", api: APIEvents, want: "reportingController=kubelet,type=Normal"},
+		{in: "message=oops", failed: true},
+		{in: "type", failed: true},
+	}
+
+	for i, tc := range table {
+		sel, err := ParseFieldSelector(tc.in)
+		if tc.failed {
+			if err == nil {
+				t.Fatalf("[tc: %d] - expected an error parsing '%s'", i, tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("[tc: %d] - unexpected error: %v", i, err)
+		}
+
+		got, err := FieldSelector(sel, tc.api)
+		if err != nil {
+			t.Fatalf("[tc: %d] - unexpected error: %v", i, err)
+		}
+		if got.String() != tc.want {
+			t.Fatalf("[tc: %d] - got: %s, expected: %s", i, got.String(), tc.want)
+		}
+	}
+}
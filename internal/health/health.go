@@ -0,0 +1,93 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Check returns an error when the checked component is unhealthy.
+type Check func() error
+
+// NewChecker creates an empty set of liveness and readiness checks.
+func NewChecker() *Checker {
+	return &Checker{
+		live:  map[string]Check{},
+		ready: map[string]Check{},
+	}
+}
+
+// Checker serves the liveness ('/healthz') and the readiness ('/readyz')
+// checks; checks can be added at any time, i.e. as the components start.
+type Checker struct {
+	mu    sync.RWMutex
+	live  map[string]Check
+	ready map[string]Check
+}
+
+// AddLivenessCheck adds a check that, when failing, means the process must be restarted.
+func (c *Checker) AddLivenessCheck(name string, fn Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.live[name] = fn
+}
+
+// AddReadinessCheck adds a check that, when failing, means the process is not able to work (yet).
+func (c *Checker) AddReadinessCheck(name string, fn Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ready[name] = fn
+}
+
+// Handler serves '/healthz' and '/readyz'; the response lists
+// the outcome of every check when the 'verbose' parameter is set.
+func (c *Checker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", c.serve(func() map[string]Check { return c.live }))
+	mux.HandleFunc("/readyz", c.serve(func() map[string]Check { return c.ready }))
+	return mux
+}
+
+func (c *Checker) serve(checks func() map[string]Check) http.HandlerFunc {
+	return func(wri http.ResponseWriter, req *http.Request) {
+		c.mu.RLock()
+		names := make([]string, 0, len(checks()))
+		all := make(map[string]Check, len(checks()))
+		for k, v := range checks() {
+			names = append(names, k)
+			all[k] = v
+		}
+		c.mu.RUnlock()
+		sort.Strings(names)
+
+		var sb strings.Builder
+		failed := false
+		for _, name := range names {
+			if err := all[name](); err != nil {
+				failed = true
+				fmt.Fprintf(&sb, "[-]%s failed: %s\n", name, err.Error())
+				continue
+			}
+			fmt.Fprintf(&sb, "[+]%s ok\n", name)
+		}
+
+		wri.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		wri.Header().Set("X-Content-Type-Options", "nosniff")
+
+		if failed {
+			wri.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(wri, sb.String())
+			fmt.Fprintf(wri, "%s check failed\n", strings.TrimPrefix(req.URL.Path, "/"))
+			return
+		}
+
+		if _, ok := req.URL.Query()["verbose"]; ok {
+			fmt.Fprint(wri, sb.String())
+			fmt.Fprintf(wri, "%s check passed\n", strings.TrimPrefix(req.URL.Path, "/"))
+			return
+		}
+		fmt.Fprint(wri, "ok")
+	}
+}
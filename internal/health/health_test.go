@@ -0,0 +1,62 @@
+package health
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestChecker(t *testing.T) {
+	var synced atomic.Bool
+
+	chk := NewChecker()
+	chk.AddLivenessCheck("ping", func() error { return nil })
+	chk.AddReadinessCheck("informer", func() error {
+		if !synced.Load() {
+			return errors.New("not synced")
+		}
+		return nil
+	})
+
+	srv := httptest.NewServer(chk.Handler())
+	defer srv.Close()
+
+	table := []struct {
+		path   string
+		synced bool
+		code   int
+		body   string
+	}{
+		{"/healthz", false, http.StatusOK, "ok"},
+		{"/readyz", false, http.StatusServiceUnavailable, "[-]informer failed: not synced"},
+		{"/readyz", true, http.StatusOK, "ok"},
+		{"/readyz?verbose", true, http.StatusOK, "[+]informer ok"},
+	}
+
+	for _, tc := range table {
+		synced.Store(tc.synced)
+
+		resp, err := http.Get(srv.URL + tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != tc.code {
+			t.Errorf("%s: expected status %d, got %d", tc.path, tc.code, resp.StatusCode)
+		}
+
+		if !strings.Contains(string(body), tc.body) {
+			t.Errorf("%s: expected body containing %q, got %q", tc.path, tc.body, body)
+		}
+	}
+}
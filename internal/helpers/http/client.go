@@ -10,11 +10,18 @@ type ClientOpts struct {
 	Verbose  bool
 	Insecure bool
 	Timeout  time.Duration
+	// TLSConfig is the optional TLS configuration of the pooled transport.
+	TLSConfig *tls.Config
 }
 
 func ClientFromOpts(opts ClientOpts) *http.Client {
 	transport := defaultTransport()
 
+	if opts.TLSConfig != nil {
+		t := transport.(*http.Transport)
+		t.TLSClientConfig = opts.TLSConfig
+	}
+
 	if opts.Insecure {
 		tlsConfig := &tls.Config{
 			InsecureSkipVerify: true,
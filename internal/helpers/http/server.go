@@ -0,0 +1,43 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+type ServerOpts struct {
+	// Name identifies the server in the logs
+	Name    string
+	Address string
+	Handler http.Handler
+}
+
+// Serve listens on the address until the stop channel is closed,
+// then it gracefully shuts down the server.
+func Serve(opts ServerOpts, stopCh <-chan struct{}) {
+	srv := &http.Server{
+		Addr:              opts.Address,
+		Handler:           opts.Handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-stopCh
+
+		ctx, cncl := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cncl()
+
+		if err := srv.Shutdown(ctx); err != nil {
+			klog.ErrorS(err, "unable to shutdown server", "name", opts.Name)
+		}
+	}()
+
+	klog.InfoS("Starting server", "name", opts.Name, "address", opts.Address)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		klog.ErrorS(err, "unable to serve", "name", opts.Name, "address", opts.Address)
+	}
+}
@@ -3,6 +3,7 @@ package queue
 import (
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // NewQueue create a queue that specifies the number of buffers and the number of worker threads
@@ -24,7 +25,13 @@ type Queue struct {
 	workerPool chan chan Jober
 	workers    []*worker
 	running    uint32
-	wg         *sync.WaitGroup
+	busy       int64
+	// progress is when (unix nano) the dispatcher last handed
+	// a job to a worker or a worker completed one
+	progress int64
+	// waiting is 1 while the dispatcher waits for a free worker
+	waiting int32
+	wg      *sync.WaitGroup
 }
 
 // Run start running queues
@@ -34,8 +41,9 @@ func (q *Queue) Run() {
 	}
 
 	atomic.StoreUint32(&q.running, 1)
+	atomic.StoreInt64(&q.progress, time.Now().UnixNano())
 	for i := 0; i < q.maxWorkers; i++ {
-		q.workers[i] = newWorker(q.workerPool, q.wg)
+		q.workers[i] = newWorker(q.workerPool, q.wg, &q.busy, &q.progress)
 		q.workers[i].Start()
 	}
 
@@ -44,8 +52,11 @@ func (q *Queue) Run() {
 
 func (q *Queue) dispatcher() {
 	for job := range q.jobQueue {
+		atomic.StoreInt32(&q.waiting, 1)
 		worker := <-q.workerPool
 		worker <- job
+		atomic.StoreInt32(&q.waiting, 0)
+		atomic.StoreInt64(&q.progress, time.Now().UnixNano())
 	}
 }
 
@@ -78,3 +89,29 @@ func (q *Queue) Push(job Jober) {
 func (q *Queue) GetJobCount() int {
 	return len(q.jobQueue)
 }
+
+// GetCapacity returns the size of the jobs buffer
+func (q *Queue) GetCapacity() int {
+	return cap(q.jobQueue)
+}
+
+// GetWorkerCount returns the number of worker threads
+func (q *Queue) GetWorkerCount() int {
+	return q.maxWorkers
+}
+
+// GetBusyWorkers returns the number of worker threads running a job
+func (q *Queue) GetBusyWorkers() int {
+	return int(atomic.LoadInt64(&q.busy))
+}
+
+// Stalled reports whether there are jobs waiting for a worker while no job
+// has been dispatched nor completed for longer than the timeout; the workers
+// busy with slow jobs still make progress, wedged ones do not
+func (q *Queue) Stalled(timeout time.Duration) bool {
+	if atomic.LoadInt32(&q.waiting) == 0 && len(q.jobQueue) == 0 {
+		return false
+	}
+	last := atomic.LoadInt64(&q.progress)
+	return time.Since(time.Unix(0, last)) > timeout
+}
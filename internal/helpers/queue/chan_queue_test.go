@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestQueue(t *testing.T) {
@@ -80,3 +81,80 @@ func BenchmarkQueue(b *testing.B) {
 	})
 	q.Terminate()
 }
+
+func TestQueueBusyWorkers(t *testing.T) {
+	q := NewQueue(1, 2)
+	q.Run()
+	defer q.Terminate()
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	for i := 0; i < 2; i++ {
+		q.Push(NewJob(i, func(v interface{}) {
+			started <- struct{}{}
+			<-release
+		}))
+	}
+	<-started
+	<-started
+
+	if got := q.GetBusyWorkers(); got != 2 {
+		t.Errorf("expected 2 busy workers, got %d", got)
+	}
+	close(release)
+}
+
+func TestQueueStalled(t *testing.T) {
+	q := NewQueue(2, 1)
+	q.Run()
+	defer q.Terminate()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	q.Push(NewJob("foo", func(v interface{}) {
+		close(started)
+		<-release
+	}))
+	<-started
+
+	if q.Stalled(time.Millisecond) {
+		t.Error("expected dispatcher not stalled with an idle queue")
+	}
+
+	q.Push(NewJob("bar", func(v interface{}) {}))
+	time.Sleep(10 * time.Millisecond)
+
+	if !q.Stalled(time.Millisecond) {
+		t.Error("expected dispatcher stalled waiting for a free worker")
+	}
+
+	close(release)
+}
+
+func TestQueueSaturatedNotStalled(t *testing.T) {
+	q := NewQueue(1, 1)
+	q.Run()
+	defer q.Terminate()
+
+	// a saturated queue of slow jobs still makes progress
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			q.Push(NewJob(i, func(v interface{}) {
+				time.Sleep(20 * time.Millisecond)
+			}))
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-time.After(5 * time.Millisecond):
+			if q.Stalled(100 * time.Millisecond) {
+				t.Fatal("expected a queue making progress not stalled")
+			}
+		}
+	}
+}
@@ -37,6 +37,8 @@ type ListQueue struct {
 	lock       *sync.RWMutex
 	wg         *sync.WaitGroup
 	running    uint32
+	busy       int64
+	progress   int64
 }
 
 // Run start running queues
@@ -47,7 +49,7 @@ func (q *ListQueue) Run() {
 	atomic.StoreUint32(&q.running, 1)
 
 	for i := 0; i < q.maxWorker; i++ {
-		q.workers[i] = newWorker(q.workerPool, q.wg)
+		q.workers[i] = newWorker(q.workerPool, q.wg, &q.busy, &q.progress)
 		q.workers[i].Start()
 	}
 
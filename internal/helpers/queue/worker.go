@@ -2,24 +2,31 @@ package queue
 
 import (
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // create a worker thread
-func newWorker(pool chan chan Jober, wg *sync.WaitGroup) *worker {
+func newWorker(pool chan chan Jober, wg *sync.WaitGroup, busy, progress *int64) *worker {
 	return &worker{
-		pool:    pool,
-		wg:      wg,
-		jobChan: make(chan Jober),
-		quit:    make(chan struct{}),
+		pool:     pool,
+		wg:       wg,
+		busy:     busy,
+		progress: progress,
+		jobChan:  make(chan Jober),
+		quit:     make(chan struct{}),
 	}
 }
 
 // worker thread
 type worker struct {
-	pool    chan chan Jober
-	wg      *sync.WaitGroup
-	jobChan chan Jober
-	quit    chan struct{}
+	pool chan chan Jober
+	wg   *sync.WaitGroup
+	busy *int64
+	// progress is when (unix nano) the last job completed
+	progress *int64
+	jobChan  chan Jober
+	quit     chan struct{}
 }
 
 // start the worker
@@ -32,7 +39,10 @@ func (w *worker) dispatcher() {
 	for {
 		select {
 		case j := <-w.jobChan:
+			atomic.AddInt64(w.busy, 1)
 			j.Job()
+			atomic.AddInt64(w.busy, -1)
+			atomic.StoreInt64(w.progress, time.Now().UnixNano())
 			w.pool <- w.jobChan
 			w.wg.Done()
 		case <-w.quit:
@@ -0,0 +1,138 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+)
+
+type ElectorOpts struct {
+	RESTConfig *rest.Config
+	// Namespace and Name of the Lease object
+	Namespace string
+	Name      string
+	// Identity of this replica, defaults to the hostname
+	Identity      string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+	// OnStartedLeading is called every time this replica becomes the leader
+	OnStartedLeading func()
+	// OnStoppedLeading is called every time this replica stops being the leader
+	OnStoppedLeading func()
+}
+
+// NewElector creates a Lease based leader elector.
+func NewElector(opts ElectorOpts) (*Elector, error) {
+	if len(opts.Namespace) == 0 {
+		return nil, fmt.Errorf("leader election namespace is required")
+	}
+
+	id := opts.Identity
+	if len(id) == 0 {
+		var err error
+		id, err = os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get leader election identity: %w", err)
+		}
+	}
+
+	cs, err := kubernetes.NewForConfig(opts.RESTConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Namespace: opts.Namespace,
+			Name:      opts.Name,
+		},
+		Client: cs.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: id,
+		},
+	}
+
+	res := &Elector{identity: id, opts: opts}
+	res.config = leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   opts.LeaseDuration,
+		RenewDeadline:   opts.RenewDeadline,
+		RetryPeriod:     opts.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            opts.Name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: res.onStartedLeading,
+			OnStoppedLeading: res.onStoppedLeading,
+			OnNewLeader: func(identity string) {
+				klog.InfoS("new leader elected", "lease", opts.Name, "leader", identity)
+			},
+		},
+	}
+
+	// validates the timings
+	if _, err := leaderelection.NewLeaderElector(res.config); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Elector campaigns for the leadership until stopped; when the leadership
+// is lost it goes back to standby and campaigns again.
+type Elector struct {
+	identity string
+	opts     ElectorOpts
+	config   leaderelection.LeaderElectionConfig
+}
+
+// Run campaigns for the leadership until the stop channel is closed,
+// then it releases the Lease so that a standby can take over at once.
+func (e *Elector) Run(stopCh <-chan struct{}) {
+	ctx, cncl := context.WithCancel(context.Background())
+	defer cncl()
+
+	go func() {
+		<-stopCh
+		cncl()
+	}()
+
+	for {
+		le, err := leaderelection.NewLeaderElector(e.config)
+		if err != nil {
+			klog.ErrorS(err, "unable to create leader elector", "lease", e.opts.Name)
+			return
+		}
+
+		klog.InfoS("campaigning for leadership", "lease", e.opts.Name, "identity", e.identity)
+		le.Run(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+	}
+}
+
+func (e *Elector) onStartedLeading(context.Context) {
+	klog.InfoS("started leading", "lease", e.opts.Name, "identity", e.identity)
+	if e.opts.OnStartedLeading != nil {
+		e.opts.OnStartedLeading()
+	}
+}
+
+func (e *Elector) onStoppedLeading() {
+	klog.InfoS("stopped leading", "lease", e.opts.Name, "identity", e.identity)
+	if e.opts.OnStoppedLeading != nil {
+		e.opts.OnStoppedLeading()
+	}
+}
@@ -0,0 +1,223 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "eventrouter"
+)
+
+// Event skip reasons.
+const (
+	SkipCompositionIDPresent = "composition_id_present"
+	SkipThrottled            = "throttled"
+	SkipStandby              = "standby"
+	SkipResync               = "resync"
+	SkipDuplicate            = "duplicate"
+	SkipStamped              = "stamped"
+	SkipNotAccepted          = "not_accepted"
+	SkipHandled              = "handled"
+)
+
+// Composition id resolution outcomes.
+const (
+	ResolutionFound    = "found"
+	ResolutionNotFound = "not_found"
+	ResolutionError    = "error"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	eventsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_received_total",
+		Help:      "Number of events received by the router.",
+	}, []string{"type", "reason"})
+
+	eventsSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_skipped_total",
+		Help:      "Number of received events not handled by the router.",
+	}, []string{"type", "reason", "skip_reason"})
+
+	resolutionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "composition_id_resolution_duration_seconds",
+		Help:      "Time spent resolving the composition id of the involved objects.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"result"})
+
+	resolutionFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "composition_id_resolution_failures_total",
+		Help:      "Number of failed composition id resolutions.",
+	})
+
+	objectCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "object_cache_lookups_total",
+		Help:      "Number of lookups of the involved objects and owners metadata cache, by result.",
+	}, []string{"result"})
+
+	compositionIDPolicy = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "composition_id_policy_total",
+		Help:      "Number of events delivered or dropped by the registrations composition id policy, by whether they are correlated.",
+	}, []string{"registration", "policy", "correlated", "result"})
+
+	leader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "leader",
+		Help:      "Whether this replica is delivering the notifications (1) or is a standby (0).",
+	})
+
+	deliveryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "delivery_duration_seconds",
+		Help:      "Duration of the notification attempts, by registration.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"registration"})
+
+	deliveryResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "delivery_responses_total",
+		Help:      "Number of endpoint responses, by registration and status code.",
+	}, []string{"registration", "code"})
+
+	deliveryFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "delivery_failures_total",
+		Help:      "Number of failed notification attempts, by registration and failure reason.",
+	}, []string{"registration", "reason"})
+
+	deliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deliveries_total",
+		Help:      "Number of notifications, after retries, by registration and result.",
+	}, []string{"registration", "result"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		eventsReceived,
+		eventsSkipped,
+		resolutionDuration,
+		resolutionFailures,
+		objectCacheLookups,
+		compositionIDPolicy,
+		leader,
+		deliveryDuration,
+		deliveryResponses,
+		deliveryFailures,
+		deliveries,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// QueueStats is implemented by the notification queues.
+type QueueStats interface {
+	GetJobCount() int
+	GetCapacity() int
+	GetWorkerCount() int
+	GetBusyWorkers() int
+}
+
+// RegisterQueue exposes the depth and the workers utilisation of the queue.
+func RegisterQueue(q QueueStats) {
+	gauge := func(name, help string, fn func() int) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "queue",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return float64(fn()) })
+	}
+
+	registry.MustRegister(
+		gauge("depth", "Number of notifications waiting for a worker.", q.GetJobCount),
+		gauge("capacity", "Size of the notification queue buffer.", q.GetCapacity),
+		gauge("workers", "Number of notification worker threads.", q.GetWorkerCount),
+		gauge("busy_workers", "Number of worker threads delivering a notification.", q.GetBusyWorkers),
+	)
+}
+
+// EventReceived counts an event received by the router.
+func EventReceived(typ, reason string) {
+	eventsReceived.WithLabelValues(typ, reason).Inc()
+}
+
+// EventSkipped counts an event not handled by the router.
+func EventSkipped(typ, reason, skipReason string) {
+	eventsSkipped.WithLabelValues(typ, reason, skipReason).Inc()
+}
+
+// CompositionIDResolved observes a composition id resolution.
+func CompositionIDResolved(result string, elapsed time.Duration) {
+	resolutionDuration.WithLabelValues(result).Observe(elapsed.Seconds())
+	if result == ResolutionError {
+		resolutionFailures.Inc()
+	}
+}
+
+// CompositionIDPolicyApplied counts an event accepted, or dropped, by
+// the registration composition id policy.
+func CompositionIDPolicyApplied(registration, policy string, correlated, accepted bool) {
+	result := "accepted"
+	if !accepted {
+		result = "dropped"
+	}
+	compositionIDPolicy.WithLabelValues(registration, policy, strconv.FormatBool(correlated), result).Inc()
+}
+
+// ObjectCacheLookup counts a lookup of the objects metadata cache.
+func ObjectCacheLookup(hit bool) {
+	result := "hit"
+	if !hit {
+		result = "miss"
+	}
+	objectCacheLookups.WithLabelValues(result).Inc()
+}
+
+// SetLeader reports whether this replica is delivering the notifications.
+func SetLeader(ok bool) {
+	if ok {
+		leader.Set(1)
+		return
+	}
+	leader.Set(0)
+}
+
+// DeliveryAttempted observes a notification attempt; the status code
+// is zero when no response was received, the reason is empty on success.
+func DeliveryAttempted(registration string, statusCode int, reason string, elapsed time.Duration) {
+	deliveryDuration.WithLabelValues(registration).Observe(elapsed.Seconds())
+	if statusCode > 0 {
+		deliveryResponses.WithLabelValues(registration, strconv.Itoa(statusCode)).Inc()
+	}
+	if len(reason) > 0 {
+		deliveryFailures.WithLabelValues(registration, reason).Inc()
+	}
+}
+
+// DeliveryCompleted counts a notification outcome, after all the retries.
+func DeliveryCompleted(registration string, ok bool) {
+	result := "success"
+	if !ok {
+		result = "failure"
+	}
+	deliveries.WithLabelValues(registration, result).Inc()
+}
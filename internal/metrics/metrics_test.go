@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDeliveryAttempted(t *testing.T) {
+	DeliveryAttempted("test", 200, "", time.Millisecond)
+	DeliveryAttempted("test", 503, "server_error", time.Millisecond)
+	DeliveryAttempted("test", 0, "transport", time.Millisecond)
+
+	if got := testutil.ToFloat64(deliveryResponses.WithLabelValues("test", "503")); got != 1 {
+		t.Errorf("expected 1 response with status 503, got %v", got)
+	}
+
+	if got := testutil.CollectAndCount(deliveryResponses); got != 2 {
+		t.Errorf("expected 2 status codes, got %d", got)
+	}
+
+	if got := testutil.CollectAndCount(deliveryFailures); got != 2 {
+		t.Errorf("expected 2 failure reasons, got %d", got)
+	}
+}
+
+func TestCompositionIDResolved(t *testing.T) {
+	CompositionIDResolved(ResolutionFound, time.Millisecond)
+	CompositionIDResolved(ResolutionError, time.Millisecond)
+
+	if got := testutil.ToFloat64(resolutionFailures); got != 1 {
+		t.Errorf("expected 1 failure, got %v", got)
+	}
+}
+
+func TestCompositionIDPolicyApplied(t *testing.T) {
+	CompositionIDPolicyApplied("test", "require", true, true)
+	CompositionIDPolicyApplied("test", "require", false, false)
+	CompositionIDPolicyApplied("test", "require", false, false)
+
+	if got := testutil.ToFloat64(compositionIDPolicy.WithLabelValues("test", "require", "false", "dropped")); got != 2 {
+		t.Errorf("expected 2 uncorrelated events dropped, got %v", got)
+	}
+}
+
+type fakeQueue struct{}
+
+func (fakeQueue) GetJobCount() int    { return 3 }
+func (fakeQueue) GetCapacity() int    { return 10 }
+func (fakeQueue) GetWorkerCount() int { return 5 }
+func (fakeQueue) GetBusyWorkers() int { return 2 }
+
+func TestRegisterQueue(t *testing.T) {
+	RegisterQueue(fakeQueue{})
+
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]float64{
+		"eventrouter_queue_depth":        3,
+		"eventrouter_queue_busy_workers": 2,
+	}
+	for _, mf := range mfs {
+		if v, ok := want[mf.GetName()]; ok {
+			if got := mf.GetMetric()[0].GetGauge().GetValue(); got != v {
+				t.Errorf("%s: expected %v, got %v", mf.GetName(), v, got)
+			}
+			delete(want, mf.GetName())
+		}
+	}
+
+	if len(want) > 0 {
+		t.Errorf("missing metrics: %v", want)
+	}
+}
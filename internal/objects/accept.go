@@ -1,22 +1,185 @@
 package objects
 
 import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
 	"strings"
+	"sync"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
 )
 
-func Accept(ref *corev1.ObjectReference) bool {
-	accept := []string{
-		".krateo.io",
+// AcceptRules select the involved objects by glob patterns of their API
+// group (i.e. '*.krateo.io') or API group and kind (i.e. 'apps/Deployment');
+// the core API group is 'core' (i.e. 'core/Pod').
+type AcceptRules struct {
+	// Allow are the accepted objects, all when empty.
+	Allow []string `json:"allow,omitempty"`
+	// Deny are the rejected objects, even if allowed.
+	Deny []string `json:"deny,omitempty"`
+}
+
+// ParsePatterns splits a comma separated list of patterns.
+func ParsePatterns(s string) []string {
+	res := []string{}
+	for _, el := range strings.Split(s, ",") {
+		if el = strings.TrimSpace(el); len(el) > 0 {
+			res = append(res, el)
+		}
+	}
+	return res
+}
+
+// Validate checks the patterns syntax.
+func (r *AcceptRules) Validate() error {
+	for _, el := range append(append([]string{}, r.Allow...), r.Deny...) {
+		group, kind, _ := strings.Cut(el, "/")
+		if _, err := path.Match(group, ""); err != nil {
+			return fmt.Errorf("invalid pattern '%s': %w", el, err)
+		}
+		if _, err := path.Match(kind, ""); err != nil {
+			return fmt.Errorf("invalid pattern '%s': %w", el, err)
+		}
+	}
+	return nil
+}
+
+// Accept reports whether the object is allowed and not denied.
+func (r *AcceptRules) Accept(ref *corev1.ObjectReference) bool {
+	gvk := ref.GroupVersionKind()
+
+	group := gvk.Group
+	if len(group) == 0 {
+		group = "core"
+	}
+
+	if matchAny(r.Deny, group, gvk.Kind) {
+		return false
+	}
+	return len(r.Allow) == 0 || matchAny(r.Allow, group, gvk.Kind)
+}
+
+func matchAny(patterns []string, group, kind string) bool {
+	for _, el := range patterns {
+		groupPattern, kindPattern, ok := strings.Cut(el, "/")
+		if m, _ := path.Match(groupPattern, group); !m {
+			continue
+		}
+		if !ok {
+			return true
+		}
+		if m, _ := path.Match(kindPattern, kind); m {
+			return true
+		}
+	}
+	return false
+}
+
+type AcceptorOpts struct {
+	// Rules are the accept rules, unless loaded from File.
+	Rules AcceptRules
+	// File, if any, holds the accept rules (YAML or JSON), reloaded when changed.
+	File string
+	// Interval between two checks of the file.
+	Interval time.Duration
+}
+
+// NewAcceptor creates the involved objects acceptance filter.
+func NewAcceptor(opts AcceptorOpts) (*Acceptor, error) {
+	if err := opts.Rules.Validate(); err != nil {
+		return nil, err
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	res := &Acceptor{
+		file:     opts.File,
+		interval: interval,
+		rules:    opts.Rules,
+	}
+
+	if len(res.file) > 0 {
+		if err := res.reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// Acceptor filters the involved objects of the events,
+// before their composition id is resolved.
+type Acceptor struct {
+	file     string
+	interval time.Duration
+
+	mu    sync.RWMutex
+	rules AcceptRules
+	data  []byte
+}
+
+// Run reloads the rules file, if any, every interval until the stop channel is closed.
+func (a *Acceptor) Run(stopCh <-chan struct{}) {
+	if len(a.file) == 0 {
+		return
+	}
+
+	wait.Until(func() {
+		if err := a.reload(); err != nil {
+			klog.ErrorS(err, "unable to reload the accept rules, keeping the previous ones", "file", a.file)
+		}
+	}, a.interval, stopCh)
+}
+
+// Accept reports whether the events of the object must be handled;
+// a nil acceptor accepts everything.
+func (a *Acceptor) Accept(ref *corev1.ObjectReference) bool {
+	if a == nil {
+		return true
 	}
 
-	apiGroup := ref.GroupVersionKind().Group
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 
-	ok := true
-	for _, el := range accept {
-		ok = ok || strings.HasSuffix(apiGroup, el)
+	return a.rules.Accept(ref)
+}
+
+// reload reads the rules file, if changed.
+func (a *Acceptor) reload() error {
+	dat, err := os.ReadFile(a.file)
+	if err != nil {
+		return err
 	}
 
-	return ok
+	a.mu.RLock()
+	same := bytes.Equal(dat, a.data)
+	a.mu.RUnlock()
+	if same {
+		return nil
+	}
+
+	rules := AcceptRules{}
+	if err := yaml.UnmarshalStrict(dat, &rules); err != nil {
+		return err
+	}
+	if err := rules.Validate(); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.rules = rules
+	a.data = dat
+	a.mu.Unlock()
+
+	klog.InfoS("accept rules loaded", "file", a.file, "allow", rules.Allow, "deny", rules.Deny)
+	return nil
 }
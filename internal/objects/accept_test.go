@@ -0,0 +1,96 @@
+package objects
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestAcceptRules(t *testing.T) {
+	pod := &corev1.ObjectReference{APIVersion: "v1", Kind: "Pod"}
+	deployment := &corev1.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment"}
+	replicaSet := &corev1.ObjectReference{APIVersion: "apps/v1", Kind: "ReplicaSet"}
+	composition := &corev1.ObjectReference{APIVersion: "composition.krateo.io/v1alpha1", Kind: "FireworksApp"}
+	nodeGroup := &corev1.ObjectReference{APIVersion: "eks.aws.crossplane.io/v1alpha1", Kind: "NodeGroup"}
+
+	table := []struct {
+		name  string
+		rules AcceptRules
+		ref   *corev1.ObjectReference
+		want  bool
+	}{
+		{name: "no rules", ref: pod, want: true},
+		{name: "allowed group", rules: AcceptRules{Allow: []string{"*.krateo.io", "*.crossplane.io"}}, ref: nodeGroup, want: true},
+		{name: "not allowed group", rules: AcceptRules{Allow: []string{"*.krateo.io", "*.crossplane.io"}}, ref: pod, want: false},
+		{name: "allowed kind", rules: AcceptRules{Allow: []string{"apps/Deployment"}}, ref: deployment, want: true},
+		{name: "not allowed kind", rules: AcceptRules{Allow: []string{"apps/Deployment"}}, ref: replicaSet, want: false},
+		{name: "denied core kind", rules: AcceptRules{Deny: []string{"core/Pod"}}, ref: pod, want: false},
+		{name: "not denied", rules: AcceptRules{Deny: []string{"core/Pod"}}, ref: composition, want: true},
+		{name: "allowed and denied", rules: AcceptRules{Allow: []string{"apps"}, Deny: []string{"apps/Replica*"}}, ref: replicaSet, want: false},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.rules.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if got := tc.rules.Accept(tc.ref); got != tc.want {
+				t.Fatalf("got: %v, expected: %v", got, tc.want)
+			}
+		})
+	}
+
+	invalid := AcceptRules{Allow: []string{"[apps"}}
+	if err := invalid.Validate(); err == nil {
+		t.Fatal("expected an invalid pattern error")
+	}
+}
+
+func TestAcceptorReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "accept.yaml")
+	if err := os.WriteFile(file, []byte("allow: ['*.krateo.io']\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewAcceptor(AcceptorOpts{
+		Rules: AcceptRules{Allow: []string{"apps"}},
+		File:  file,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deployment := &corev1.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment"}
+	composition := &corev1.ObjectReference{APIVersion: "composition.krateo.io/v1alpha1", Kind: "FireworksApp"}
+
+	if a.Accept(deployment) || !a.Accept(composition) {
+		t.Fatal("expected the file rules overriding the given ones")
+	}
+
+	if err := os.WriteFile(file, []byte("allow: ['apps', '*.krateo.io']\ndeny: ['*/FireworksApp']\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Accept(deployment) || a.Accept(composition) {
+		t.Fatal("expected the reloaded rules")
+	}
+
+	if err := os.WriteFile(file, []byte("allow: ['[apps']\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.reload(); err == nil {
+		t.Fatal("expected an invalid rules error")
+	}
+	if !a.Accept(deployment) {
+		t.Fatal("expected the previous rules kept")
+	}
+
+	var none *Acceptor
+	if !none.Accept(deployment) {
+		t.Fatal("expected a nil acceptor accepting everything")
+	}
+}
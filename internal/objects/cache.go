@@ -0,0 +1,260 @@
+package objects
+
+import (
+	"sync"
+	"time"
+
+	"github.com/krateoplatformops/eventrouter/internal/metrics"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilcache "k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+type MetadataCacheOpts struct {
+	RESTConfig *rest.Config
+	// TTL of the cached objects, zero disables the cache
+	TTL time.Duration
+	// Size is the maximum number of cached objects
+	Size int
+	// Informers watches the metadata of every observed kind
+	Informers bool
+	// InformerKinds are the glob patterns of the API groups, or API groups
+	// and kinds (i.e. 'apps/Deployment'), of the watched kinds, all when empty
+	InformerKinds []string
+	// MaxInformers bounds the number of watched kinds, unbounded when zero
+	MaxInformers   int
+	ResyncInterval time.Duration
+}
+
+// NewMetadataCache creates a cache of the resolved objects metadata.
+func NewMetadataCache(opts MetadataCacheOpts) (*MetadataCache, error) {
+	resolver, err := NewObjectResolver(opts.RESTConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &MetadataCache{
+		resolver:     resolver,
+		ttl:          opts.TTL,
+		resync:       opts.ResyncInterval,
+		kinds:        AcceptRules{Allow: opts.InformerKinds},
+		maxInformers: opts.MaxInformers,
+		informers:    map[schema.GroupVersionKind]*metadataInformer{},
+	}
+
+	if opts.TTL > 0 {
+		size := opts.Size
+		if size <= 0 {
+			size = 4096
+		}
+		res.objects = utilcache.NewLRUExpireCache(size)
+	}
+
+	if opts.Informers {
+		client, err := metadata.NewForConfig(opts.RESTConfig)
+		if err != nil {
+			return nil, err
+		}
+		res.client = client
+	}
+
+	return res, nil
+}
+
+// MetadataCache holds the metadata (labels, annotations, owner references) of the
+// resolved objects, by UID, for a TTL; with the informers enabled, the metadata of
+// the objects of every observed kind is watched and served locally once synced.
+type MetadataCache struct {
+	// resolver maps the observed kinds to their resources
+	resolver *ObjectResolver
+	objects  *utilcache.LRUExpireCache
+	ttl      time.Duration
+
+	client       metadata.Interface
+	resync       time.Duration
+	kinds        AcceptRules
+	maxInformers int
+	mu           sync.Mutex
+	stopCh       <-chan struct{}
+	// informers are the watched kinds, nil for the ones not watched
+	informers map[schema.GroupVersionKind]*metadataInformer
+}
+
+type metadataInformer struct {
+	informer   cache.SharedIndexInformer
+	namespaced bool
+}
+
+// Run keeps the informers, started as the kinds are observed, running until
+// the stop channel is closed.
+func (c *MetadataCache) Run(stopCh <-chan struct{}) {
+	c.mu.Lock()
+	c.stopCh = stopCh
+	for _, el := range c.informers {
+		if el != nil {
+			go el.informer.Run(stopCh)
+		}
+	}
+	c.mu.Unlock()
+
+	<-stopCh
+}
+
+// Get returns the metadata of the referenced object, if cached.
+func (c *MetadataCache) Get(ref *corev1.ObjectReference) (*unstructured.Unstructured, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	obj, ok := c.get(ref)
+	metrics.ObjectCacheLookup(ok)
+	return obj, ok
+}
+
+func (c *MetadataCache) get(ref *corev1.ObjectReference) (*unstructured.Unstructured, bool) {
+	gvk := ref.GroupVersionKind()
+
+	if inf := c.informer(gvk); inf != nil && inf.informer.HasSynced() {
+		key := ref.Name
+		if inf.namespaced {
+			key = ref.Namespace + "/" + ref.Name
+		}
+
+		obj, ok, err := inf.informer.GetStore().GetByKey(key)
+		if err != nil || !ok {
+			return nil, false
+		}
+
+		m, err := meta.Accessor(obj)
+		if err != nil || (len(ref.UID) > 0 && m.GetUID() != ref.UID) {
+			return nil, false
+		}
+		return metadataOf(gvk, m), true
+	}
+
+	if c.objects == nil || len(ref.UID) == 0 {
+		return nil, false
+	}
+
+	obj, ok := c.objects.Get(ref.UID)
+	if !ok {
+		return nil, false
+	}
+	return obj.(*unstructured.Unstructured), true
+}
+
+// Add caches the metadata of a resolved object.
+func (c *MetadataCache) Add(obj *unstructured.Unstructured) {
+	if c == nil {
+		return
+	}
+
+	if c.objects != nil && len(obj.GetUID()) > 0 {
+		c.objects.Add(obj.GetUID(), metadataOf(obj.GroupVersionKind(), obj), c.ttl)
+	}
+}
+
+// informer returns the metadata informer of the kind, starting it if missing.
+func (c *MetadataCache) informer(gvk schema.GroupVersionKind) *metadataInformer {
+	if c.client == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if inf, ok := c.informers[gvk]; ok {
+		return inf
+	}
+
+	if !c.watchable(gvk) {
+		c.informers[gvk] = nil
+		return nil
+	}
+
+	mapping, err := c.resolver.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		klog.V(4).ErrorS(err, "unable to watch the metadata of the kind", "gvk", gvk)
+		return nil
+	}
+
+	inf := &metadataInformer{
+		informer: metadatainformer.NewFilteredMetadataInformer(c.client, mapping.Resource,
+			metav1.NamespaceAll, c.resync, cache.Indexers{}, nil).Informer(),
+		namespaced: mapping.Scope.Name() == meta.RESTScopeNameNamespace,
+	}
+
+	// drops the objects cached before the informer sync
+	inf.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, obj interface{}) { c.forget(obj) },
+		DeleteFunc: c.forget,
+	})
+
+	if c.stopCh != nil {
+		go inf.informer.Run(c.stopCh)
+	}
+	c.informers[gvk] = inf
+
+	klog.InfoS("watching objects metadata", "gvk", gvk)
+	return inf
+}
+
+// watchable reports whether the kind can be watched, being allowed and within the
+// bound of watched kinds; the caller must hold the lock.
+func (c *MetadataCache) watchable(gvk schema.GroupVersionKind) bool {
+	if !c.kinds.Accept(&corev1.ObjectReference{APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind}) {
+		klog.V(4).InfoS("kind not allowed, its objects metadata is not watched", "gvk", gvk)
+		return false
+	}
+
+	if c.maxInformers <= 0 {
+		return true
+	}
+
+	watched := 0
+	for _, el := range c.informers {
+		if el != nil {
+			watched++
+		}
+	}
+	if watched >= c.maxInformers {
+		klog.InfoS("too many kinds watched, its objects metadata is not watched",
+			"gvk", gvk, "max", c.maxInformers)
+		return false
+	}
+	return true
+}
+
+func (c *MetadataCache) forget(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	m, err := meta.Accessor(obj)
+	if err != nil || c.objects == nil {
+		return
+	}
+	c.objects.Remove(m.GetUID())
+}
+
+// metadataOf returns an object with only the metadata of the given one.
+func metadataOf(gvk schema.GroupVersionKind, m metav1.Object) *unstructured.Unstructured {
+	res := &unstructured.Unstructured{}
+	res.SetGroupVersionKind(gvk)
+	res.SetNamespace(m.GetNamespace())
+	res.SetName(m.GetName())
+	res.SetUID(m.GetUID())
+	res.SetResourceVersion(m.GetResourceVersion())
+	res.SetLabels(m.GetLabels())
+	res.SetAnnotations(m.GetAnnotations())
+	res.SetOwnerReferences(m.GetOwnerReferences())
+	return res
+}
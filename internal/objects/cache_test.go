@@ -0,0 +1,108 @@
+package objects
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+func TestMetadataCache(t *testing.T) {
+	c, err := NewMetadataCache(MetadataCacheOpts{
+		RESTConfig: &rest.Config{Host: "http://localhost:0"},
+		TTL:        time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("apps/v1")
+	obj.SetKind("Deployment")
+	obj.SetNamespace("demo")
+	obj.SetName("web")
+	obj.SetUID("1234")
+	obj.SetLabels(map[string]string{"krateo.io/composition-id": "abcd"})
+	if err := unstructured.SetNestedField(obj.Object, int64(3), "spec", "replicas"); err != nil {
+		t.Fatal(err)
+	}
+	c.Add(obj)
+
+	ref := &corev1.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "demo", Name: "web", UID: "1234"}
+	got, ok := c.Get(ref)
+	if !ok {
+		t.Fatal("expected the object cached by UID")
+	}
+	if got.GetLabels()["krateo.io/composition-id"] != "abcd" || got.GetName() != "web" {
+		t.Fatalf("unexpected cached metadata: %v", got.Object)
+	}
+	if _, ok := got.Object["spec"]; ok {
+		t.Fatal("expected only the metadata cached")
+	}
+
+	ref.UID = ""
+	if _, ok := c.Get(ref); ok {
+		t.Fatal("expected no lookup without UID")
+	}
+
+	c.forget(obj)
+	ref.UID = "1234"
+	if _, ok := c.Get(ref); ok {
+		t.Fatal("expected the forgotten object not cached")
+	}
+
+	var none *MetadataCache
+	none.Add(obj)
+	if _, ok := none.Get(ref); ok {
+		t.Fatal("expected no object from a nil cache")
+	}
+}
+
+func TestMetadataCacheInformerKinds(t *testing.T) {
+	c, err := NewMetadataCache(MetadataCacheOpts{
+		RESTConfig:    &rest.Config{Host: "http://localhost:0"},
+		Informers:     true,
+		InformerKinds: []string{"*.krateo.io", "apps/Deployment"},
+		MaxInformers:  1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deployment := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	composition := schema.GroupVersionKind{Group: "composition.krateo.io", Version: "v1", Kind: "FireworksApp"}
+	pod := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+
+	table := []struct {
+		gvk  schema.GroupVersionKind
+		want bool
+	}{
+		{deployment, true},
+		{composition, true},
+		{pod, false},
+		{schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"}, false},
+	}
+
+	for _, tc := range table {
+		if got := c.watchable(tc.gvk); got != tc.want {
+			t.Errorf("%s: expected watchable %v, got %v", tc.gvk, tc.want, got)
+		}
+	}
+
+	// not allowed kinds are remembered as not watched
+	if inf := c.informer(pod); inf != nil {
+		t.Fatal("expected no informer for a kind not allowed")
+	}
+	if inf, ok := c.informers[pod]; !ok || inf != nil {
+		t.Fatal("expected the kind not allowed remembered")
+	}
+
+	// the watched kinds are bounded
+	c.informers[deployment] = &metadataInformer{}
+	if c.watchable(composition) {
+		t.Error("expected no more kinds watched beyond the bound")
+	}
+}
@@ -97,6 +97,8 @@ type PatchOpts struct {
 	GVK       schema.GroupVersionKind
 	Name      string
 	Namespace string
+	// Subresource to patch (i.e. 'status'), optional.
+	Subresource string
 }
 
 func (r *ObjectResolver) Patch(ctx context.Context, opts PatchOpts) error {
@@ -110,9 +112,14 @@ func (r *ObjectResolver) Patch(ctx context.Context, opts PatchOpts) error {
 		return err
 	}
 
+	var subresources []string
+	if len(opts.Subresource) > 0 {
+		subresources = append(subresources, opts.Subresource)
+	}
+
 	_, err = dri.Patch(ctx, opts.Name, types.MergePatchType, opts.PatchData, metav1.PatchOptions{
 		FieldManager: "krateo",
-	})
+	}, subresources...)
 	if err != nil {
 		if !apierrors.IsNotFound(err) {
 			return err
@@ -0,0 +1,226 @@
+// Package refs resolves the values referenced by Registrations (i.e. Secret and ConfigMap keys).
+//
+// Only the referenced objects are watched: the first lookup of an
+// object starts an informer restricted to it, so that the following
+// lookups are served locally and always reflect its latest content.
+//
+// Registrations are cluster-scoped, so the objects can be referenced
+// only in the allowed namespaces, otherwise any Secret could be sent
+// to an endpoint.
+package refs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// syncTimeout bounds the wait for a newly watched object
+	syncTimeout = 10 * time.Second
+)
+
+// AllNamespaces allows the references to any namespace.
+const AllNamespaces = "*"
+
+type ResolverOpts struct {
+	RESTConfig *rest.Config
+	// Client, if set, is used instead of a client for RESTConfig
+	Client         kubernetes.Interface
+	ResyncInterval time.Duration
+	// Namespaces are the namespaces the references are allowed to,
+	// AllNamespaces for any; none when empty
+	Namespaces []string
+}
+
+// NewResolver creates a resolver of Secret and ConfigMap keys.
+func NewResolver(opts ResolverOpts) (*Resolver, error) {
+	client := opts.Client
+	if client == nil {
+		clientSet, err := kubernetes.NewForConfig(opts.RESTConfig)
+		if err != nil {
+			return nil, err
+		}
+		client = clientSet
+	}
+
+	namespaces := make(map[string]struct{}, len(opts.Namespaces))
+	for _, ns := range opts.Namespaces {
+		namespaces[ns] = struct{}{}
+	}
+
+	return &Resolver{
+		client:         client,
+		resyncInterval: opts.ResyncInterval,
+		namespaces:     namespaces,
+		watched:        map[objectKey]cache.SharedInformer{},
+		stop:           make(chan struct{}),
+	}, nil
+}
+
+// Resolver serves the referenced values from per-object informers.
+type Resolver struct {
+	client         kubernetes.Interface
+	resyncInterval time.Duration
+	namespaces     map[string]struct{}
+
+	mu      sync.Mutex
+	watched map[objectKey]cache.SharedInformer
+	stop    chan struct{}
+}
+
+type objectKey struct {
+	resource  string
+	namespace string
+	name      string
+}
+
+// Run blocks until the stop channel is closed, then stops all the informers.
+func (r *Resolver) Run(stopCh <-chan struct{}) {
+	<-stopCh
+	close(r.stop)
+}
+
+// SecretValue returns the value of the key of the Secret.
+func (r *Resolver) SecretValue(namespace, name, key string) ([]byte, error) {
+	obj, err := r.get(objectKey{resource: "secrets", namespace: namespace, name: name}, &corev1.Secret{})
+	if err != nil {
+		return nil, err
+	}
+
+	sec := obj.(*corev1.Secret)
+	val, ok := sec.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("key '%s' not found in secret '%s/%s'", key, namespace, name)
+	}
+
+	return val, nil
+}
+
+// ConfigMapValue returns the value of the key of the ConfigMap.
+func (r *Resolver) ConfigMapValue(namespace, name, key string) ([]byte, error) {
+	obj, err := r.get(objectKey{resource: "configmaps", namespace: namespace, name: name}, &corev1.ConfigMap{})
+	if err != nil {
+		return nil, err
+	}
+
+	cm := obj.(*corev1.ConfigMap)
+	if val, ok := cm.Data[key]; ok {
+		return []byte(val), nil
+	}
+
+	if val, ok := cm.BinaryData[key]; ok {
+		return val, nil
+	}
+
+	return nil, fmt.Errorf("key '%s' not found in configmap '%s/%s'", key, namespace, name)
+}
+
+// Allowed reports whether the objects of the namespace can be referenced.
+func (r *Resolver) Allowed(namespace string) bool {
+	if _, ok := r.namespaces[AllNamespaces]; ok {
+		return true
+	}
+	_, ok := r.namespaces[namespace]
+	return ok
+}
+
+// get returns the object from its informer store,
+// starting the informer at the first lookup.
+func (r *Resolver) get(k objectKey, objType runtime.Object) (interface{}, error) {
+	if !r.Allowed(k.namespace) {
+		return nil, fmt.Errorf("%s '%s/%s' not allowed, references to namespace '%s' are disabled",
+			k.resource, k.namespace, k.name, k.namespace)
+	}
+
+	inf, err := r.informerFor(k, objType)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, ok, err := inf.GetStore().GetByKey(k.namespace + "/" + k.name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("%s '%s/%s' not found", k.resource, k.namespace, k.name)
+	}
+
+	return obj, nil
+}
+
+func (r *Resolver) informerFor(k objectKey, objType runtime.Object) (cache.SharedInformer, error) {
+	r.mu.Lock()
+	inf, ok := r.watched[k]
+	if !ok {
+		inf = cache.NewSharedInformer(r.listWatch(k), objType, r.resyncInterval)
+		r.watched[k] = inf
+
+		klog.V(4).InfoS("watching referenced object",
+			"resource", k.resource, "namespace", k.namespace, "name", k.name)
+
+		go inf.Run(r.stop)
+	}
+	r.mu.Unlock()
+
+	if inf.HasSynced() {
+		return inf, nil
+	}
+
+	timeout := make(chan struct{})
+	timer := time.AfterFunc(syncTimeout, func() { close(timeout) })
+	defer timer.Stop()
+
+	if !cache.WaitForCacheSync(timeout, inf.HasSynced) {
+		return nil, fmt.Errorf("timed out waiting for %s '%s/%s'", k.resource, k.namespace, k.name)
+	}
+
+	return inf, nil
+}
+
+// listWatch lists and watches only the referenced object.
+func (r *Resolver) listWatch(k objectKey) cache.ListerWatcher {
+	selector := fields.OneTermEqualSelector("metadata.name", k.name).String()
+
+	var (
+		list    func(context.Context, metav1.ListOptions) (runtime.Object, error)
+		watchFn func(context.Context, metav1.ListOptions) (watch.Interface, error)
+	)
+	switch k.resource {
+	case "secrets":
+		cli := r.client.CoreV1().Secrets(k.namespace)
+		list = func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return cli.List(ctx, opts)
+		}
+		watchFn = cli.Watch
+	default:
+		cli := r.client.CoreV1().ConfigMaps(k.namespace)
+		list = func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return cli.List(ctx, opts)
+		}
+		watchFn = cli.Watch
+	}
+
+	return &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			opts.FieldSelector = selector
+			return list(context.Background(), opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = selector
+			return watchFn(context.Background(), opts)
+		},
+	}
+}
@@ -0,0 +1,118 @@
+package refs
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestResolver(t *testing.T, namespaces ...string) *Resolver {
+	t.Helper()
+
+	client := fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "demo-system"},
+			Data:       map[string][]byte{"token": []byte("s3cr3t")},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: "demo-system"},
+			Data:       map[string]string{"ca.crt": "pem"},
+			BinaryData: map[string][]byte{"ca.der": []byte("der")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "kube-system"},
+			Data:       map[string][]byte{"token": []byte("admin")},
+		},
+	)
+
+	res, err := NewResolver(ResolverOpts{Client: client, Namespaces: namespaces})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	go res.Run(stop)
+	t.Cleanup(func() { close(stop) })
+
+	return res
+}
+
+func TestResolverValues(t *testing.T) {
+	res := newTestResolver(t, "demo-system")
+
+	table := []struct {
+		resource string
+		name     string
+		key      string
+		want     string
+		err      string
+	}{
+		{resource: "secrets", name: "creds", key: "token", want: "s3cr3t"},
+		{resource: "secrets", name: "creds", key: "missing", err: "key 'missing' not found"},
+		{resource: "secrets", name: "missing", key: "token", err: "secrets 'demo-system/missing' not found"},
+		{resource: "configmaps", name: "ca", key: "ca.crt", want: "pem"},
+		{resource: "configmaps", name: "ca", key: "ca.der", want: "der"},
+		{resource: "configmaps", name: "ca", key: "missing", err: "key 'missing' not found"},
+	}
+
+	for _, tc := range table {
+		var (
+			got []byte
+			err error
+		)
+		if tc.resource == "secrets" {
+			got, err = res.SecretValue("demo-system", tc.name, tc.key)
+		} else {
+			got, err = res.ConfigMapValue("demo-system", tc.name, tc.key)
+		}
+
+		if len(tc.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s/%s/%s: expected error '%s', got %v", tc.resource, tc.name, tc.key, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s/%s/%s: unexpected error: %s", tc.resource, tc.name, tc.key, err)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("%s/%s/%s: expected '%s', got '%s'", tc.resource, tc.name, tc.key, tc.want, got)
+		}
+	}
+}
+
+func TestResolverNamespaces(t *testing.T) {
+	table := []struct {
+		namespaces []string
+		allowed    bool
+	}{
+		{namespaces: nil, allowed: false},
+		{namespaces: []string{"demo-system"}, allowed: false},
+		{namespaces: []string{"demo-system", "kube-system"}, allowed: true},
+		{namespaces: []string{AllNamespaces}, allowed: true},
+	}
+
+	for _, tc := range table {
+		res := newTestResolver(t, tc.namespaces...)
+
+		got, err := res.SecretValue("kube-system", "creds", "token")
+		if !tc.allowed {
+			if err == nil || !strings.Contains(err.Error(), "not allowed") {
+				t.Errorf("%v: expected the reference not allowed, got '%s', %v", tc.namespaces, got, err)
+			}
+			continue
+		}
+		if err != nil || string(got) != "admin" {
+			t.Errorf("%v: expected the reference allowed, got '%s', %v", tc.namespaces, got, err)
+		}
+	}
+
+	res := newTestResolver(t)
+	if len(res.watched) != 0 {
+		t.Errorf("expected no objects watched in the not allowed namespaces")
+	}
+}
@@ -3,70 +3,273 @@ package router
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"text/template"
 	"time"
 
 	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+	"github.com/krateoplatformops/eventrouter/internal/deadletter"
+	"github.com/krateoplatformops/eventrouter/internal/metrics"
+	"github.com/krateoplatformops/eventrouter/internal/refs"
+	"github.com/krateoplatformops/eventrouter/pkg/signature"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
 )
 
 type advOpts struct {
-	httpClient       *http.Client
+	clients          *clientPool
+	registrationName string
 	registrationSpec v1alpha1.RegistrationSpec
+	template         *template.Template
 	eventInfo        corev1.Event
+	objectLabels     map[string]string
+	backoff          wait.Backoff
+	deadLetter       *deadletter.Store
+	recorder         *StatusRecorder
+	refs             *refs.Resolver
+	watermarks       *Watermarks
+	retrier          *Retrier
 }
 
 func newAdvisor(opts advOpts) *advisor {
 	return &advisor{
-		httpClient: opts.httpClient,
+		clients:    opts.clients,
+		name:       opts.registrationName,
 		reg:        opts.registrationSpec,
+		tpl:        opts.template,
 		evt:        opts.eventInfo,
+		objLabels:  opts.objectLabels,
+		backoff:    opts.backoff,
+		retries:    opts.backoff.Steps,
+		deadLetter: opts.deadLetter,
+		recorder:   opts.recorder,
+		refs:       opts.refs,
+		watermarks: opts.watermarks,
+		retrier:    opts.retrier,
 	}
 }
 
 type advisor struct {
-	httpClient *http.Client
-	reg        v1alpha1.RegistrationSpec
-	evt        corev1.Event
+	clients   *clientPool
+	name      string
+	reg       v1alpha1.RegistrationSpec
+	tpl       *template.Template
+	evt       corev1.Event
+	objLabels map[string]string
+	// the backoff is a value, every job consumes its own copy;
+	// retries are counted apart since reaching the cap zeroes the steps
+	backoff    wait.Backoff
+	retries    int
+	deadLetter *deadletter.Store
+	recorder   *StatusRecorder
+	refs       *refs.Resolver
+	watermarks *Watermarks
+	retrier    *Retrier
+
+	// the delivery state carried over the retries
+	pay      *payload
+	attempts int
+	lastErr  *deliveryError
 }
 
+// Job makes a delivery attempt; a retryable failure is rescheduled on the
+// retrier after the backoff delay instead of holding the worker meanwhile.
 func (c *advisor) Job() {
-	err := c.notify()
-	if err != nil {
-		klog.Errorf("unable to notify %s: %s", c.reg.ServiceName, err.Error())
+	compositionId := c.compositionId()
+
+	if c.pay == nil {
+		pay, err := encodePayload(&c.reg, c.tpl,
+			newTemplateData(&c.evt, compositionId, c.objLabels))
+		if err != nil {
+			klog.Errorf("unable to notify %s: cannot encode notification (compositionId:%s, destinationURL:%s): %s",
+				c.reg.ServiceName, compositionId, c.reg.Endpoint, err.Error())
+			c.record(err)
+			metrics.DeliveryCompleted(c.name, false)
+			c.watermarks.done(c.name, &c.evt)
+			return
+		}
+		c.pay = pay
+	}
+
+	c.attempts++
+	start := time.Now()
+
+	statusCode, derr := c.notify(c.pay)
+	if derr == nil {
+		metrics.DeliveryAttempted(c.name, statusCode, "", time.Since(start))
+		metrics.DeliveryCompleted(c.name, true)
+		c.record(nil)
+		c.watermarks.done(c.name, &c.evt)
+		return
+	}
+	metrics.DeliveryAttempted(c.name, statusCode, derr.reason, time.Since(start))
+	c.lastErr = derr
+
+	if derr.retryable && c.attempts <= c.retries {
+		delay := c.retryDelay(derr)
+		if c.retrier.after(delay, c) {
+			klog.V(4).InfoS("notification failed, retrying",
+				"serviceName", c.reg.ServiceName,
+				"endpoint", c.reg.Endpoint,
+				"attempt", c.attempts,
+				"delay", delay,
+				"reason", derr.reason,
+				"statusCode", derr.statusCode,
+				"err", derr.Error())
+			return
+		}
 	}
+
+	c.fail(compositionId, derr)
 }
 
-func (c *advisor) notify() error {
-	compositionId := ""
-	if labels := c.evt.GetLabels(); len(labels) > 0 {
-		compositionId = labels[keyCompositionID]
+// retryDelay returns the delay before the next attempt, the next backoff step
+// unless the endpoint asked for a longer one, capped anyway to the backoff cap.
+func (c *advisor) retryDelay(derr *deliveryError) time.Duration {
+	delay := c.backoff.Step()
+	if derr.retryAfter > delay {
+		delay = derr.retryAfter
+		if c.backoff.Cap > 0 && delay > c.backoff.Cap {
+			delay = c.backoff.Cap
+		}
+	}
+	return delay
+}
+
+// cancel gives up the retries on shutdown: with watermarks the notification, never handled,
+// is notified again after the restart; otherwise it's failed, so that it's dead-lettered.
+func (c *advisor) cancel() {
+	if c.watermarks != nil {
+		klog.InfoS("notification retry cancelled on shutdown",
+			"registration", c.name, "endpoint", c.reg.Endpoint, "attempts", c.attempts)
+		return
+	}
+
+	c.fail(c.compositionId(), &deliveryError{
+		reason:     reasonCancelled,
+		statusCode: c.lastErr.statusCode,
+		err:        fmt.Errorf("retry cancelled on shutdown: %w", c.lastErr),
+	})
+}
+
+// fail reports the delivery as failed, storing the undelivered notification.
+func (c *advisor) fail(compositionId string, derr *deliveryError) {
+	defer c.watermarks.done(c.name, &c.evt)
+
+	klog.ErrorS(derr, "unable to notify",
+		"serviceName", c.reg.ServiceName,
+		"endpoint", c.reg.Endpoint,
+		"compositionId", compositionId,
+		"attempts", c.attempts,
+		"reason", derr.reason,
+		"statusCode", derr.statusCode)
+
+	c.record(derr)
+	metrics.DeliveryCompleted(c.name, false)
+
+	if c.deadLetter == nil {
+		return
 	}
 
-	dat, err := json.Marshal(c.evt)
+	err := c.deadLetter.Put(deadletter.Entry{
+		Registration: c.name,
+		Endpoint:     c.reg.Endpoint,
+		Attempts:     c.attempts,
+		Reason:       derr.reason,
+		StatusCode:   derr.statusCode,
+		Error:        derr.Error(),
+		ContentType:  c.pay.header.Get("Content-Type"),
+		Payload:      deadletter.RawPayload(c.pay.body),
+	})
 	if err != nil {
-		return fmt.Errorf("cannot encode notification (compositionId:%s, destinationURL:%s): %w",
-			compositionId, c.reg.Endpoint, err)
+		klog.ErrorS(err, "unable to store undelivered notification",
+			"registration", c.name, "compositionId", compositionId)
 	}
+}
+
+// record reports the delivery outcome to the status recorder, if any.
+func (c *advisor) record(err error) {
+	if c.recorder == nil {
+		return
+	}
+	c.recorder.record(c.name, err)
+}
+
+func (c *advisor) compositionId() string {
+	if labels := c.evt.GetLabels(); len(labels) > 0 {
+		return labels[keyCompositionID]
+	}
+	return ""
+}
+
+// notify sends the payload returning the response status code, if any.
+func (c *advisor) notify(pay *payload) (int, *deliveryError) {
+	compositionId := c.compositionId()
 
 	ctx, cncl := context.WithTimeout(context.Background(), time.Second*40)
 	defer cncl()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.reg.Endpoint, bytes.NewBuffer(dat))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.reg.Endpoint, bytes.NewBuffer(pay.body))
+	if err != nil {
+		return 0, &deliveryError{
+			reason: reasonRequest,
+			err: fmt.Errorf("cannot create notification (compositionId:%s, destinationURL:%s): %w",
+				compositionId, c.reg.Endpoint, err),
+		}
+	}
+
+	for k, v := range pay.header {
+		req.Header[k] = v
+	}
+
+	if err := applyAuth(req, c.reg.Auth, c.refs); err != nil {
+		return 0, &deliveryError{
+			reason: reasonConfig,
+			err: fmt.Errorf("cannot authenticate notification (compositionId:%s, destinationURL:%s): %w",
+				compositionId, c.reg.Endpoint, err),
+		}
+	}
+
+	if sig := c.reg.Signing; sig != nil {
+		ref := sig.SecretKeyRef
+		key, err := c.refs.SecretValue(ref.Namespace, ref.Name, ref.Key)
+		if err != nil {
+			return 0, &deliveryError{
+				reason: reasonConfig,
+				err: fmt.Errorf("cannot resolve signing key (compositionId:%s, destinationURL:%s): %w",
+					compositionId, c.reg.Endpoint, err),
+			}
+		}
+		signature.SetHeaders(req.Header, key, time.Now(), pay.body)
+	}
+
+	httpClient, err := c.clients.get(c.reg.TLS)
 	if err != nil {
-		return fmt.Errorf("cannot create notification (compositionId:%s, destinationURL:%s): %w",
-			compositionId, c.reg.Endpoint, err)
+		return 0, &deliveryError{
+			reason: reasonConfig,
+			err: fmt.Errorf("cannot configure TLS (compositionId:%s, destinationURL:%s): %w",
+				compositionId, c.reg.Endpoint, err),
+		}
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	_, err = c.httpClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("cannot send notification (deploymentId:%s, destinationURL:%s): %w",
-			compositionId, c.reg.Endpoint, err)
+		return 0, &deliveryError{
+			reason:    reasonTransport,
+			retryable: true,
+			err: fmt.Errorf("cannot send notification (compositionId:%s, destinationURL:%s): %w",
+				compositionId, c.reg.Endpoint, err),
+		}
+	}
+
+	if err := checkResponse(resp); err != nil {
+		derr := err.(*deliveryError)
+		derr.err = fmt.Errorf("notification rejected (compositionId:%s, destinationURL:%s): %w",
+			compositionId, c.reg.Endpoint, derr.err)
+		return derr.statusCode, derr
 	}
 
-	return nil
+	return resp.StatusCode, nil
 }
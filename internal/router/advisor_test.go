@@ -0,0 +1,189 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+	"github.com/krateoplatformops/eventrouter/internal/deadletter"
+	"github.com/krateoplatformops/eventrouter/internal/helpers/queue"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// testQueue hands the pushed jobs to the test, that runs them.
+type testQueue struct {
+	jobs chan queue.Jober
+}
+
+func (q *testQueue) Run()                 {}
+func (q *testQueue) Terminate()           {}
+func (q *testQueue) Push(job queue.Jober) { q.jobs <- job }
+
+// drain runs the rescheduled jobs until none is pushed for a while.
+func (q *testQueue) drain() {
+	for {
+		select {
+		case job := <-q.jobs:
+			job.Job()
+		case <-time.After(200 * time.Millisecond):
+			return
+		}
+	}
+}
+
+func newTestAdvisor(t *testing.T, codes ...int) (*advisor, *testQueue, *int64) {
+	t.Helper()
+
+	var hits int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&hits, 1)
+		code := codes[len(codes)-1]
+		if int(n) <= len(codes) {
+			code = codes[n-1]
+		}
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(srv.Close)
+
+	dl, err := deadletter.NewStore(deadletter.StoreOpts{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := &testQueue{jobs: make(chan queue.Jober, 1)}
+
+	adv := newAdvisor(advOpts{
+		clients:          newClientPool(false, false, nil),
+		registrationName: "test",
+		registrationSpec: v1alpha1.RegistrationSpec{ServiceName: "test", Endpoint: srv.URL},
+		eventInfo:        *samplePayloadEvent(),
+		backoff:          wait.Backoff{Duration: time.Millisecond, Factor: 2, Steps: 3, Cap: 5 * time.Millisecond},
+		deadLetter:       dl,
+		retrier:          NewRetrier(RetrierOpts{Queue: q}),
+	})
+	return adv, q, &hits
+}
+
+func TestAdvisorRetries(t *testing.T) {
+	table := []struct {
+		codes      []int
+		attempts   int
+		deadLetter bool
+	}{
+		{codes: []int{http.StatusOK}, attempts: 1},
+		{codes: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, attempts: 3},
+		{codes: []int{http.StatusServiceUnavailable}, attempts: 4, deadLetter: true},
+		{codes: []int{http.StatusServiceUnavailable, http.StatusBadRequest}, attempts: 2, deadLetter: true},
+	}
+
+	for i, tc := range table {
+		adv, q, hits := newTestAdvisor(t, tc.codes...)
+		adv.Job()
+		q.drain()
+
+		if got := atomic.LoadInt64(hits); int(got) != tc.attempts || adv.attempts != tc.attempts {
+			t.Errorf("[%d] expected %d attempts, got %d (requests %d)", i, tc.attempts, adv.attempts, got)
+		}
+
+		entries := adv.deadLetter.List()
+		if !tc.deadLetter {
+			if len(entries) != 0 {
+				t.Errorf("[%d] unexpected dead-letter entries: %v", i, entries)
+			}
+			continue
+		}
+
+		if len(entries) != 1 {
+			t.Fatalf("[%d] expected a dead-letter entry, got %d", i, len(entries))
+		}
+		got := entries[0]
+		if got.Registration != "test" || got.Attempts != tc.attempts ||
+			got.StatusCode != tc.codes[len(tc.codes)-1] || len(got.Payload) == 0 {
+			t.Errorf("[%d] unexpected dead-letter entry: %+v", i, got)
+		}
+	}
+}
+
+func TestAdvisorRetryDelay(t *testing.T) {
+	adv := newAdvisor(advOpts{
+		backoff: wait.Backoff{Duration: 10 * time.Millisecond, Factor: 1, Steps: 3, Cap: time.Second},
+	})
+
+	table := []struct {
+		retryAfter time.Duration
+		want       time.Duration
+	}{
+		{want: 10 * time.Millisecond},
+		{retryAfter: time.Millisecond, want: 10 * time.Millisecond},
+		{retryAfter: 500 * time.Millisecond, want: 500 * time.Millisecond},
+		{retryAfter: time.Hour, want: time.Second},
+	}
+
+	for i, tc := range table {
+		got := adv.retryDelay(&deliveryError{retryable: true, retryAfter: tc.retryAfter})
+		if got != tc.want {
+			t.Errorf("[%d] expected delay %v, got %v", i, tc.want, got)
+		}
+	}
+}
+
+func TestRetrierStop(t *testing.T) {
+	table := []struct {
+		name       string
+		watermarks *Watermarks
+		// stopped first, the retry is cancelled as it's scheduled
+		stopFirst bool
+		want      int
+	}{
+		// replayed after the restart, not dead-lettered
+		{name: "watermarks", watermarks: newTestWatermarks(time.Now()), want: 0},
+		{name: "no watermarks", want: 1},
+		{name: "no watermarks, stopped", stopFirst: true, want: 1},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			adv, q, hits := newTestAdvisor(t, http.StatusServiceUnavailable)
+			adv.backoff.Duration = time.Hour
+			adv.watermarks = tc.watermarks
+
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				adv.retrier.Run(stop)
+				close(done)
+			}()
+
+			if tc.stopFirst {
+				close(stop)
+				<-done
+				adv.Job()
+			} else {
+				adv.Job()
+				close(stop)
+				<-done
+			}
+			q.drain()
+
+			if got := atomic.LoadInt64(hits); got != 1 {
+				t.Errorf("expected a single attempt, got %d", got)
+			}
+			if n := len(adv.retrier.pending); n != 0 {
+				t.Errorf("expected no pending retries, got %d", n)
+			}
+			if n := adv.deadLetter.Len(); n != tc.want {
+				t.Fatalf("expected %d dead-lettered notifications, got %d", tc.want, n)
+			}
+
+			if tc.want > 0 {
+				e := adv.deadLetter.List()[0]
+				if e.Reason != reasonCancelled || e.StatusCode != http.StatusServiceUnavailable {
+					t.Errorf("unexpected dead-letter entry: %+v", e)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+	"github.com/krateoplatformops/eventrouter/internal/refs"
+)
+
+// applyAuth sets the authentication headers, their values
+// are read from the referenced secrets.
+func applyAuth(req *http.Request, auth *v1alpha1.AuthSpec, resolver *refs.Resolver) error {
+	if auth == nil {
+		return nil
+	}
+
+	secretValue := func(ref v1alpha1.SecretKeySelector) (string, error) {
+		val, err := resolver.SecretValue(ref.Namespace, ref.Name, ref.Key)
+		return string(val), err
+	}
+
+	for _, el := range auth.Headers {
+		val, err := secretValue(el.ValueSecretRef)
+		if err != nil {
+			return fmt.Errorf("cannot resolve header '%s': %w", el.Name, err)
+		}
+		req.Header.Set(el.Name, val)
+	}
+
+	if ref := auth.BearerTokenSecretRef; ref != nil {
+		token, err := secretValue(*ref)
+		if err != nil {
+			return fmt.Errorf("cannot resolve bearer token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	if basic := auth.Basic; basic != nil {
+		username, err := secretValue(basic.UsernameSecretRef)
+		if err != nil {
+			return fmt.Errorf("cannot resolve basic auth username: %w", err)
+		}
+
+		password, err := secretValue(basic.PasswordSecretRef)
+		if err != nil {
+			return fmt.Errorf("cannot resolve basic auth password: %w", err)
+		}
+		req.SetBasicAuth(username, password)
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+	"github.com/krateoplatformops/eventrouter/internal/refs"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestApplyAuth(t *testing.T) {
+	resolver, err := refs.NewResolver(refs.ResolverOpts{
+		Client: fake.NewSimpleClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "auth", Namespace: "demo-system"},
+			Data: map[string][]byte{
+				"token":    []byte("t0k3n"),
+				"username": []byte("admin"),
+				"password": []byte("s3cr3t"),
+				"apiKey":   []byte("k3y"),
+			},
+		}),
+		Namespaces: []string{"demo-system"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go resolver.Run(stop)
+
+	ref := func(key string) v1alpha1.SecretKeySelector {
+		return v1alpha1.SecretKeySelector{Name: "auth", Namespace: "demo-system", Key: key}
+	}
+	refPtr := func(key string) *v1alpha1.SecretKeySelector {
+		res := ref(key)
+		return &res
+	}
+
+	table := []struct {
+		name    string
+		auth    *v1alpha1.AuthSpec
+		headers map[string]string
+		err     string
+	}{
+		{name: "none"},
+		{
+			name:    "bearer",
+			auth:    &v1alpha1.AuthSpec{BearerTokenSecretRef: refPtr("token")},
+			headers: map[string]string{"Authorization": "Bearer t0k3n"},
+		},
+		{
+			name: "basic",
+			auth: &v1alpha1.AuthSpec{Basic: &v1alpha1.BasicAuthSpec{
+				UsernameSecretRef: ref("username"),
+				PasswordSecretRef: ref("password"),
+			}},
+			headers: map[string]string{"Authorization": "Basic YWRtaW46czNjcjN0"},
+		},
+		{
+			name: "headers",
+			auth: &v1alpha1.AuthSpec{Headers: []v1alpha1.HeaderSpec{
+				{Name: "X-Api-Key", ValueSecretRef: ref("apiKey")},
+			}},
+			headers: map[string]string{"X-Api-Key": "k3y"},
+		},
+		{
+			name: "missing key",
+			auth: &v1alpha1.AuthSpec{BearerTokenSecretRef: refPtr("missing")},
+			err:  "cannot resolve bearer token",
+		},
+		{
+			name: "missing secret",
+			auth: &v1alpha1.AuthSpec{Basic: &v1alpha1.BasicAuthSpec{
+				UsernameSecretRef: v1alpha1.SecretKeySelector{Name: "missing", Namespace: "demo-system", Key: "username"},
+				PasswordSecretRef: ref("password"),
+			}},
+			err: "cannot resolve basic auth username",
+		},
+		{
+			name: "namespace not allowed",
+			auth: &v1alpha1.AuthSpec{Headers: []v1alpha1.HeaderSpec{
+				{Name: "X-Api-Key", ValueSecretRef: v1alpha1.SecretKeySelector{Name: "auth", Namespace: "kube-system", Key: "apiKey"}},
+			}},
+			err: "not allowed",
+		},
+	}
+
+	for _, tc := range table {
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost", nil)
+
+		err := applyAuth(req, tc.auth, resolver)
+		if len(tc.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: expected error '%s', got %v", tc.name, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err)
+			continue
+		}
+
+		for k, v := range tc.headers {
+			if got := req.Header.Get(k); got != v {
+				t.Errorf("%s: expected header %s '%s', got '%s'", tc.name, k, v, got)
+			}
+		}
+		if len(tc.headers) == 0 && len(req.Header) != 0 {
+			t.Errorf("%s: unexpected headers: %v", tc.name, req.Header)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package router
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+	httpHelper "github.com/krateoplatformops/eventrouter/internal/helpers/http"
+	"github.com/krateoplatformops/eventrouter/internal/refs"
+)
+
+const (
+	// clientIdleTTL is how long an unused per-TLS configuration client is kept
+	clientIdleTTL = 10 * time.Minute
+)
+
+func newClientPool(verbose, insecure bool, resolver *refs.Resolver) *clientPool {
+	return &clientPool{
+		verbose:  verbose,
+		insecure: insecure,
+		refs:     resolver,
+		shared: httpHelper.ClientFromOpts(httpHelper.ClientOpts{
+			Verbose:  verbose,
+			Insecure: insecure,
+		}),
+		clients: map[string]*pooledClient{},
+	}
+}
+
+// clientPool holds one http.Client per distinct TLS configuration,
+// registrations without TLS settings use the shared client.
+type clientPool struct {
+	verbose  bool
+	insecure bool
+	refs     *refs.Resolver
+	shared   *http.Client
+
+	mu      sync.Mutex
+	clients map[string]*pooledClient
+}
+
+type pooledClient struct {
+	client   *http.Client
+	lastUsed time.Time
+}
+
+// get returns the client for the TLS settings, the referenced
+// material is resolved on every call so that rotated
+// certificates result in a new client.
+func (p *clientPool) get(spec *v1alpha1.TLSSpec) (*http.Client, error) {
+	if spec == nil {
+		return p.shared, nil
+	}
+
+	mat, err := p.resolve(spec)
+	if err != nil {
+		return nil, err
+	}
+	key := mat.hash()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	p.prune(now)
+
+	if el, ok := p.clients[key]; ok {
+		el.lastUsed = now
+		return el.client, nil
+	}
+
+	cfg, err := mat.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	cli := httpHelper.ClientFromOpts(httpHelper.ClientOpts{
+		Verbose:   p.verbose,
+		TLSConfig: cfg,
+	})
+	p.clients[key] = &pooledClient{client: cli, lastUsed: now}
+
+	return cli, nil
+}
+
+// prune drops the clients unused for a while, the caller must hold the lock.
+func (p *clientPool) prune(now time.Time) {
+	for k, el := range p.clients {
+		if now.Sub(el.lastUsed) > clientIdleTTL {
+			el.client.CloseIdleConnections()
+			delete(p.clients, k)
+		}
+	}
+}
+
+// tlsMaterial is the resolved content of a TLS configuration.
+type tlsMaterial struct {
+	ca         []byte
+	cert       []byte
+	key        []byte
+	serverName string
+	insecure   bool
+}
+
+func (p *clientPool) resolve(spec *v1alpha1.TLSSpec) (*tlsMaterial, error) {
+	res := &tlsMaterial{
+		serverName: spec.ServerName,
+		insecure:   p.insecure || spec.InsecureSkipVerify,
+	}
+
+	var err error
+	if ca := spec.CA; ca != nil {
+		switch {
+		case ca.ConfigMapKeyRef != nil:
+			ref := ca.ConfigMapKeyRef
+			res.ca, err = p.refs.ConfigMapValue(ref.Namespace, ref.Name, ref.Key)
+		case ca.SecretKeyRef != nil:
+			ref := ca.SecretKeyRef
+			res.ca, err = p.refs.SecretValue(ref.Namespace, ref.Name, ref.Key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve CA bundle: %w", err)
+		}
+	}
+
+	if cc := spec.ClientCertificate; cc != nil {
+		ref := cc.CertSecretRef
+		res.cert, err = p.refs.SecretValue(ref.Namespace, ref.Name, ref.Key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve client certificate: %w", err)
+		}
+
+		ref = cc.KeySecretRef
+		res.key, err = p.refs.SecretValue(ref.Namespace, ref.Name, ref.Key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve client key: %w", err)
+		}
+	}
+
+	return res, nil
+}
+
+func (m *tlsMaterial) hash() string {
+	h := sha256.New()
+	for _, el := range [][]byte{m.ca, m.cert, m.key, []byte(m.serverName)} {
+		fmt.Fprintf(h, "%d:", len(el))
+		h.Write(el)
+	}
+	fmt.Fprintf(h, "%t", m.insecure)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (m *tlsMaterial) tlsConfig() (*tls.Config, error) {
+	res := &tls.Config{
+		ServerName:         m.serverName,
+		InsecureSkipVerify: m.insecure,
+	}
+
+	if len(m.ca) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(m.ca) {
+			return nil, fmt.Errorf("no valid certificates found in CA bundle")
+		}
+		res.RootCAs = pool
+	}
+
+	if len(m.cert) > 0 {
+		cert, err := tls.X509KeyPair(m.cert, m.key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		res.Certificates = []tls.Certificate{cert}
+	}
+
+	return res, nil
+}
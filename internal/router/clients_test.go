@@ -0,0 +1,72 @@
+package router
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httpHelper "github.com/krateoplatformops/eventrouter/internal/helpers/http"
+)
+
+func TestTLSMaterialConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ca := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: srv.Certificate().Raw,
+	})
+
+	table := []struct {
+		name string
+		mat  tlsMaterial
+		ok   bool
+	}{
+		{"system roots only", tlsMaterial{}, false},
+		{"custom ca", tlsMaterial{ca: ca}, true},
+		{"insecure", tlsMaterial{insecure: true}, true},
+	}
+
+	for _, tc := range table {
+		cfg, err := tc.mat.tlsConfig()
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		cli := httpHelper.ClientFromOpts(httpHelper.ClientOpts{TLSConfig: cfg})
+		resp, err := cli.Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		if got := err == nil; got != tc.ok {
+			t.Errorf("%s: expected success %v, got error %v", tc.name, tc.ok, err)
+		}
+	}
+}
+
+func TestTLSMaterialInvalid(t *testing.T) {
+	if _, err := (&tlsMaterial{ca: []byte("garbage")}).tlsConfig(); err == nil {
+		t.Error("expected error with invalid CA bundle")
+	}
+
+	if _, err := (&tlsMaterial{cert: []byte("garbage"), key: []byte("garbage")}).tlsConfig(); err == nil {
+		t.Error("expected error with invalid client certificate")
+	}
+}
+
+func TestTLSMaterialHash(t *testing.T) {
+	a := tlsMaterial{ca: []byte("ab"), cert: []byte("c")}
+	b := tlsMaterial{ca: []byte("a"), cert: []byte("bc")}
+	if a.hash() == b.hash() {
+		t.Error("expected different hashes")
+	}
+
+	c := tlsMaterial{ca: []byte("ab"), cert: []byte("c")}
+	if a.hash() != c.hash() {
+		t.Error("expected equal hashes")
+	}
+}
@@ -0,0 +1,104 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// keyCorrelations annotates the notified events with all the correlation
+	// keys found on the composition source, as a JSON object
+	keyCorrelations = "krateo.io/correlations"
+)
+
+// CorrelationKey is a label or annotation correlating the objects to a composition.
+type CorrelationKey struct {
+	Annotation bool
+	Name       string
+}
+
+func (k CorrelationKey) String() string {
+	if k.Annotation {
+		return "annotation:" + k.Name
+	}
+	return "label:" + k.Name
+}
+
+// DefaultCorrelationKeys correlates the objects by the composition id label.
+var DefaultCorrelationKeys = []CorrelationKey{{Name: keyCompositionID}}
+
+// ParseCorrelationKeys parses a comma separated list of correlation keys,
+// 'label:' or 'annotation:' prefixed (labels if omitted).
+func ParseCorrelationKeys(s string) ([]CorrelationKey, error) {
+	res := []CorrelationKey{}
+	for _, el := range strings.Split(s, ",") {
+		el = strings.TrimSpace(el)
+		if len(el) == 0 {
+			continue
+		}
+
+		key := CorrelationKey{Name: el}
+		switch {
+		case strings.HasPrefix(el, "label:"):
+			key.Name = strings.TrimPrefix(el, "label:")
+		case strings.HasPrefix(el, "annotation:"):
+			key.Name = strings.TrimPrefix(el, "annotation:")
+			key.Annotation = true
+		}
+
+		if errs := validation.IsQualifiedName(key.Name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid correlation key '%s': %s", el, strings.Join(errs, ", "))
+		}
+		res = append(res, key)
+	}
+
+	if len(res) == 0 {
+		return DefaultCorrelationKeys, nil
+	}
+	return res, nil
+}
+
+// correlate returns the values of the correlation keys found on the object, by key (i.e.
+// 'label:app.kubernetes.io/instance'), so that a label and an annotation with the same
+// name do not collide; the first found, in the keys order, is the composition id.
+func correlate(obj *unstructured.Unstructured, keys []CorrelationKey) (string, map[string]string) {
+	labels, annotations := obj.GetLabels(), obj.GetAnnotations()
+
+	cid := ""
+	res := map[string]string{}
+	for _, k := range keys {
+		src := labels
+		if k.Annotation {
+			src = annotations
+		}
+
+		val := src[k.Name]
+		if _, ok := res[k.String()]; ok || len(val) == 0 {
+			continue
+		}
+
+		res[k.String()] = val
+		if len(cid) == 0 {
+			cid = val
+		}
+	}
+	return cid, res
+}
+
+// eventCorrelations returns the correlations annotated on a notified event.
+func eventCorrelations(annotations map[string]string) map[string]string {
+	dat, ok := annotations[keyCorrelations]
+	if !ok {
+		return nil
+	}
+
+	res := map[string]string{}
+	if err := json.Unmarshal([]byte(dat), &res); err != nil {
+		return nil
+	}
+	return res
+}
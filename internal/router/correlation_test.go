@@ -0,0 +1,93 @@
+package router
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestParseCorrelationKeys(t *testing.T) {
+	table := []struct {
+		in     string
+		want   []CorrelationKey
+		failed bool
+	}{
+		{in: "", want: DefaultCorrelationKeys},
+		{
+			in: "label:krateo.io/composition-id, annotation:meta.helm.sh/release-name,app.kubernetes.io/instance",
+			want: []CorrelationKey{
+				{Name: "krateo.io/composition-id"},
+				{Name: "meta.helm.sh/release-name", Annotation: true},
+				{Name: "app.kubernetes.io/instance"},
+			},
+		},
+		{in: "label:", failed: true},
+		{in: "annotation:not a key", failed: true},
+	}
+
+	for i, tc := range table {
+		got, err := ParseCorrelationKeys(tc.in)
+		if tc.failed {
+			if err == nil {
+				t.Fatalf("[tc: %d] - expected an error parsing '%s'", i, tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("[tc: %d] - unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("[tc: %d] - got: %v, expected: %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestCorrelate(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetLabels(map[string]string{
+		"app.kubernetes.io/instance": "web",
+	})
+	obj.SetAnnotations(map[string]string{
+		"meta.helm.sh/release-name": "web-release",
+	})
+
+	keys, err := ParseCorrelationKeys("label:krateo.io/composition-id,annotation:meta.helm.sh/release-name,label:app.kubernetes.io/instance")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cid, got := correlate(obj, keys)
+	if cid != "web-release" {
+		t.Fatalf("expected the first correlation key found as composition id, got %q", cid)
+	}
+
+	want := map[string]string{
+		"annotation:meta.helm.sh/release-name": "web-release",
+		"label:app.kubernetes.io/instance":     "web",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got: %v, expected: %v", got, want)
+	}
+
+	// a label and an annotation with the same name do not collide
+	obj.SetAnnotations(map[string]string{"app.kubernetes.io/instance": "web-annotated"})
+	keys, err = ParseCorrelationKeys("label:app.kubernetes.io/instance,annotation:app.kubernetes.io/instance")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, got = correlate(obj, keys)
+	want = map[string]string{
+		"label:app.kubernetes.io/instance":      "web",
+		"annotation:app.kubernetes.io/instance": "web-annotated",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got: %v, expected: %v", got, want)
+	}
+
+	cid, got = correlate(obj, DefaultCorrelationKeys)
+	if cid != "" || len(got) != 0 {
+		t.Fatalf("expected no correlation, got %q, %v", cid, got)
+	}
+}
@@ -0,0 +1,64 @@
+package router
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	varEvent         = "event"
+	varCompositionID = "compositionId"
+)
+
+var celEnv = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable(varEvent, cel.DynType),
+		cel.Variable(varCompositionID, cel.StringType),
+	)
+})
+
+// compileExpression compiles a registration CEL filter expression,
+// the expression must evaluate to a boolean.
+func compileExpression(expr string) (cel.Program, error) {
+	env, err := celEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	ast, iss := env.Compile(expr)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("expression must evaluate to a bool, got %s", ast.OutputType())
+	}
+
+	return env.Program(ast)
+}
+
+// evalExpression evaluates the program against the event and its composition id.
+func evalExpression(prg cel.Program, evt *corev1.Event, compositionId string) (bool, error) {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(evt)
+	if err != nil {
+		return false, err
+	}
+
+	out, _, err := prg.Eval(map[string]any{
+		varEvent:         obj,
+		varCompositionID: compositionId,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	res, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expression evaluated to %v, expected a bool", out.Value())
+	}
+	return res, nil
+}
@@ -0,0 +1,64 @@
+package router
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestCompileExpression(t *testing.T) {
+	table := []struct {
+		expr  string
+		valid bool
+	}{
+		{`event.count > 3 && event.reason.startsWith("Cannot")`, true},
+		{`compositionId != ""`, true},
+		{`event.reason.startsWith(`, false},
+		{`"not a bool"`, false},
+		{`unknown > 1`, false},
+	}
+
+	for _, tc := range table {
+		_, err := compileExpression(tc.expr)
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.expr, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: expected an error", tc.expr)
+		}
+	}
+}
+
+func TestEvalExpression(t *testing.T) {
+	evt := &corev1.Event{
+		Type:   corev1.EventTypeWarning,
+		Reason: "CannotCreateExternalResource",
+		Count:  5,
+	}
+
+	table := []struct {
+		expr string
+		want bool
+	}{
+		{`event.count > 3 && event.reason.startsWith("Cannot")`, true},
+		{`event.count > 10`, false},
+		{`event.type == "Warning" && compositionId == "abcde12345"`, true},
+		{`compositionId == ""`, false},
+	}
+
+	for _, tc := range table {
+		prg, err := compileExpression(tc.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.expr, err)
+		}
+
+		got, err := evalExpression(prg, evt, "abcde12345")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.expr, err)
+		}
+
+		if got != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.expr, tc.want, got)
+		}
+	}
+}
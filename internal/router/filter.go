@@ -0,0 +1,129 @@
+package router
+
+import (
+	"path"
+
+	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// filterInput is what the registration filters are evaluated against.
+type filterInput struct {
+	evt           *corev1.Event
+	compositionId string
+	objectLabels  labels.Set
+	// nsLabels resolves the event namespace labels,
+	// it's called only if a namespace selector is specified
+	nsLabels func() (labels.Set, error)
+}
+
+// matchFilter reports whether the event satisfies all
+// the criteria of the filter; a nil filter matches everything.
+func matchFilter(f *v1alpha1.RegistrationFilter, in *filterInput) (bool, error) {
+	if f == nil {
+		return true, nil
+	}
+
+	evt := in.evt
+	if !matchAny(f.Types, evt.Type) {
+		return false, nil
+	}
+
+	if !matchAny(f.Reasons, evt.Reason) {
+		return false, nil
+	}
+
+	if len(f.ExcludedReasons) > 0 && matchAny(f.ExcludedReasons, evt.Reason) {
+		return false, nil
+	}
+
+	gvk := evt.InvolvedObject.GroupVersionKind()
+	if !matchAny(f.Kinds, gvk.Kind) {
+		return false, nil
+	}
+
+	if !matchGlob(f.APIGroups, gvk.Group) {
+		return false, nil
+	}
+
+	if !matchAny(f.Namespaces, evt.Namespace) {
+		return false, nil
+	}
+
+	if f.ObjectSelector != nil {
+		ok, err := matchSelector(f.ObjectSelector, in.objectLabels)
+		if !ok || err != nil {
+			return false, err
+		}
+	}
+
+	if f.NamespaceSelector != nil {
+		nsLabels, err := in.nsLabels()
+		if err != nil {
+			return false, err
+		}
+
+		ok, err := matchSelector(f.NamespaceSelector, nsLabels)
+		if !ok || err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// matchAny reports whether the value is in the list, an empty list matches everything.
+func matchAny(list []string, val string) bool {
+	if len(list) == 0 {
+		return true
+	}
+
+	for _, el := range list {
+		if el == val {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob reports whether the value matches one of the glob patterns,
+// an empty list matches everything.
+func matchGlob(patterns []string, val string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, el := range patterns {
+		if ok, _ := path.Match(el, val); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSelector(sel *metav1.LabelSelector, set labels.Set) (bool, error) {
+	s, err := metav1.LabelSelectorAsSelector(sel)
+	if err != nil {
+		return false, err
+	}
+	return s.Matches(set), nil
+}
+
+// namespaceLabels returns a function resolving (once) the labels of the namespace.
+func namespaceLabels(lookup *NamespaceLabels, name string) func() (labels.Set, error) {
+	var (
+		res  labels.Set
+		err  error
+		done bool
+	)
+
+	return func() (labels.Set, error) {
+		if !done && len(name) > 0 {
+			res, err = lookup.Get(name)
+		}
+		done = true
+		return res, err
+	}
+}
@@ -0,0 +1,94 @@
+package router
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestMatchFilter(t *testing.T) {
+	evt := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-1-ng.170c791ccd13d0cd",
+			Namespace: "demo-system",
+		},
+		Type:   corev1.EventTypeWarning,
+		Reason: "CannotCreateExternalResource",
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "eks.aws.crossplane.io/v1alpha1",
+			Kind:       "NodeGroup",
+			Name:       "test-1-ng",
+		},
+	}
+
+	in := &filterInput{
+		evt:          evt,
+		objectLabels: labels.Set{"app": "demo"},
+		nsLabels: func() (labels.Set, error) {
+			return labels.Set{"tenant": "acme"}, nil
+		},
+	}
+
+	table := []struct {
+		name   string
+		filter *v1alpha1.RegistrationFilter
+		want   bool
+	}{
+		{"nil filter", nil, true},
+		{"empty filter", &v1alpha1.RegistrationFilter{}, true},
+		{"type", &v1alpha1.RegistrationFilter{Types: []string{"Warning"}}, true},
+		{"other type", &v1alpha1.RegistrationFilter{Types: []string{"Normal"}}, false},
+		{"reason", &v1alpha1.RegistrationFilter{Reasons: []string{"CannotCreateExternalResource"}}, true},
+		{"excluded reason", &v1alpha1.RegistrationFilter{ExcludedReasons: []string{"CannotCreateExternalResource"}}, false},
+		{"kind", &v1alpha1.RegistrationFilter{Kinds: []string{"Pod", "NodeGroup"}}, true},
+		{"other kind", &v1alpha1.RegistrationFilter{Kinds: []string{"Pod"}}, false},
+		{"api group glob", &v1alpha1.RegistrationFilter{APIGroups: []string{"*.crossplane.io"}}, true},
+		{"core api group", &v1alpha1.RegistrationFilter{APIGroups: []string{""}}, false},
+		{"namespace", &v1alpha1.RegistrationFilter{Namespaces: []string{"demo-system"}}, true},
+		{"other namespace", &v1alpha1.RegistrationFilter{Namespaces: []string{"default"}}, false},
+		{"object selector", &v1alpha1.RegistrationFilter{
+			ObjectSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "demo"}},
+		}, true},
+		{"object selector mismatch", &v1alpha1.RegistrationFilter{
+			ObjectSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "other"}},
+		}, false},
+		{"namespace selector", &v1alpha1.RegistrationFilter{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "acme"}},
+		}, true},
+		{"all criteria", &v1alpha1.RegistrationFilter{
+			Types:     []string{"Warning"},
+			APIGroups: []string{"*.crossplane.io"},
+			Kinds:     []string{"Pod"},
+		}, false},
+	}
+
+	for _, tc := range table {
+		got, err := matchFilter(tc.filter, in)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestMatchFilterNamespaceError(t *testing.T) {
+	in := &filterInput{
+		evt: &corev1.Event{},
+		nsLabels: func() (labels.Set, error) {
+			return nil, errors.New("boom")
+		},
+	}
+
+	_, err := matchFilter(&v1alpha1.RegistrationFilter{
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "acme"}},
+	}, in)
+	if err == nil {
+		t.Error("expected namespace resolution error")
+	}
+}
@@ -1,26 +1,51 @@
 package router
 
 import (
-	"context"
-	"net/http"
+	"encoding/json"
 
-	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
-	httpHelper "github.com/krateoplatformops/eventrouter/internal/helpers/http"
+	"github.com/krateoplatformops/eventrouter/internal/deadletter"
 	"github.com/krateoplatformops/eventrouter/internal/helpers/queue"
+	"github.com/krateoplatformops/eventrouter/internal/metrics"
 	"github.com/krateoplatformops/eventrouter/internal/objects"
+	"github.com/krateoplatformops/eventrouter/internal/refs"
 
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 )
 
 type PusherOpts struct {
-	RESTConfig *rest.Config
-	Queue      queue.Queuer
-	Verbose    bool
-	Insecure   bool
+	RESTConfig    *rest.Config
+	Registrations *RegistrationStore
+	Queue         queue.Queuer
+	Verbose       bool
+	Insecure      bool
+	// Backoff drives the delivery retries, Steps is the number of retries
+	Backoff wait.Backoff
+	// Retrier reschedules the failed deliveries, a new one on Queue when nil
+	Retrier *Retrier
+	// DeadLetter collects the notifications that exhausted their retries
+	DeadLetter *deadletter.Store
+	// Recorder reports the delivery outcomes on the registrations status
+	Recorder *StatusRecorder
+	// Refs resolves the Secret keys referenced by the registrations
+	Refs *refs.Resolver
+	// Watermarks, if any, skip the events already handled before a restart
+	Watermarks *Watermarks
+	// OwnerReferencesDepth is how many owner levels are walked looking
+	// for the composition id of an involved object not labelled with it
+	OwnerReferencesDepth int
+	// CorrelationKeys are the ordered labels and annotations correlating
+	// the objects to a composition, DefaultCorrelationKeys when empty
+	CorrelationKeys []CorrelationKey
+	// ObjectCache, if any, holds the metadata of the resolved objects
+	ObjectCache *objects.MetadataCache
+	// Namespaces resolves the labels matched by the namespace selectors,
+	// a new one for RESTConfig when nil
+	Namespaces *NamespaceLabels
+	// StampEvents patches the resolved composition id label onto the events
+	StampEvents bool
 }
 
 func NewPusher(opts PusherOpts) (EventHandler, error) {
@@ -29,14 +54,41 @@ func NewPusher(opts PusherOpts) (EventHandler, error) {
 		return nil, err
 	}
 
+	keys := opts.CorrelationKeys
+	if len(keys) == 0 {
+		keys = DefaultCorrelationKeys
+	}
+
+	retrier := opts.Retrier
+	if retrier == nil {
+		retrier = NewRetrier(RetrierOpts{Queue: opts.Queue})
+	}
+
+	namespaces := opts.Namespaces
+	if namespaces == nil {
+		namespaces, err = NewNamespaceLabels(NamespaceLabelsOpts{RESTConfig: opts.RESTConfig})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &pusher{
 		objectResolver: objectResolver,
+		resolveObject:  resolveWithRetry(objectResolver, opts.ObjectCache),
+		registrations:  opts.Registrations,
 		notifyQueue:    opts.Queue,
 		verbose:        opts.Verbose,
-		httpClient: httpHelper.ClientFromOpts(httpHelper.ClientOpts{
-			Verbose:  opts.Verbose,
-			Insecure: opts.Insecure,
-		}),
+		backoff:        opts.Backoff,
+		retrier:        retrier,
+		deadLetter:     opts.DeadLetter,
+		recorder:       opts.Recorder,
+		refs:           opts.Refs,
+		watermarks:     opts.Watermarks,
+		ownerDepth:     opts.OwnerReferencesDepth,
+		keys:           keys,
+		namespaces:     namespaces,
+		stamp:          opts.StampEvents,
+		clients:        newClientPool(opts.Verbose, opts.Insecure, opts.Refs),
 	}, nil
 }
 
@@ -44,20 +96,50 @@ var _ EventHandler = (*pusher)(nil)
 
 type pusher struct {
 	objectResolver *objects.ObjectResolver
+	resolveObject  resolveFunc
+	registrations  *RegistrationStore
 	notifyQueue    queue.Queuer
-	httpClient     *http.Client
+	clients        *clientPool
+	backoff        wait.Backoff
+	retrier        *Retrier
+	deadLetter     *deadletter.Store
+	recorder       *StatusRecorder
+	refs           *refs.Resolver
+	watermarks     *Watermarks
+	ownerDepth     int
+	keys           []CorrelationKey
+	namespaces     *NamespaceLabels
+	stamp          bool
 	verbose        bool
 }
 
 func (c *pusher) Handle(evt corev1.Event) {
 	ref := &evt.InvolvedObject
 
-	compositionId, err := findCompositionID(c.objectResolver, ref)
+	all := c.registrations.List()
+	if len(all) == 0 {
+		klog.V(4).InfoS("no registrations to notify", "involvedObject", ref.Name)
+		return
+	}
+
+	// skips the composition id resolution of the events handled before a restart
+	if c.watermarks.handledAll(all, &evt) {
+		metrics.EventSkipped(evt.Type, evt.Reason, metrics.SkipHandled)
+		klog.V(2).InfoS("event already handled before the restart", "name", evt.Name, "involvedObject", ref.Name)
+		return
+	}
+
+	// the events already labelled (i.e. stamped) need no composition id resolution
+	labelled := hasCompositionId(&evt)
+
+	res, err := c.resolve(all, &evt, labelled)
 	if err != nil {
 		klog.ErrorS(err, "looking for composition id", "involvedObject", ref.Name)
 		return
 	}
 
+	compositionId := res.compositionId
+
 	klog.V(4).InfoS(evt.Message,
 		"name", evt.Name,
 		"kind", ref.Kind,
@@ -65,77 +147,113 @@ func (c *pusher) Handle(evt corev1.Event) {
 		"reason", evt.Reason,
 		"compositionId", compositionId)
 
-	all, err := c.getAllRegistrations(context.Background())
-	if err != nil {
-		klog.ErrorS(err, "unable to list registrations", "involvedObject", ref.Name)
-		return
-	}
-
 	if len(evt.ManagedFields) == 0 {
 		evt.ManagedFields = nil
 	}
 
-	labels := evt.GetLabels()
-	if labels == nil {
-		labels = map[string]string{
-			keyCompositionID: compositionId,
+	if len(compositionId) > 0 {
+		labels := evt.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
 		}
-	} else {
 		labels[keyCompositionID] = compositionId
+		evt.SetLabels(labels)
 	}
-	evt.SetLabels(labels)
 
-	c.notifyAll(all, evt)
-}
+	if res.source != nil {
+		annotations := evt.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[keyCompositionSource] = cloudEventSource(res.source)
+		if dat, err := json.Marshal(res.correlations); err == nil {
+			annotations[keyCorrelations] = string(dat)
+		}
+		evt.SetAnnotations(annotations)
+	}
 
-func (c *pusher) notifyAll(all map[string]v1alpha1.RegistrationSpec, evt corev1.Event) {
-	for _, el := range all {
-		job := newAdvisor(advOpts{
-			httpClient:       c.httpClient,
-			registrationSpec: el,
-			eventInfo:        evt,
-		})
+	c.notifyAll(all, evt, &filterInput{
+		evt:           &evt,
+		compositionId: compositionId,
+		objectLabels:  res.labels,
+		nsLabels:      namespaceLabels(c.namespaces, evt.Namespace),
+	})
 
-		c.notifyQueue.Push(job)
+	if c.stamp && !labelled && len(compositionId) > 0 {
+		stampEvent(c.objectResolver, &evt)
 	}
 }
 
-func (c *pusher) getAllRegistrations(ctx context.Context) (map[string]v1alpha1.RegistrationSpec, error) {
-	all, err := c.objectResolver.List(ctx, schema.GroupVersionKind{
-		Group:   "eventrouter.krateo.io",
-		Version: "v1alpha1",
-		Kind:    "Registration",
-	}, "")
-
-	res := map[string]v1alpha1.RegistrationSpec{}
-	if err != nil {
-		return res, err
+// resolve looks for the composition id of the event involved object; for the events already
+// labelled with it, the involved object labels are resolved only if any registration needs them.
+func (c *pusher) resolve(all []*registration, evt *corev1.Event, labelled bool) (resolution, error) {
+	if !labelled {
+		return findCompositionID(c.resolveObject, &evt.InvolvedObject, c.keys, c.ownerDepth)
 	}
 
-	if all == nil {
+	res := resolution{compositionId: evt.GetLabels()[keyCompositionID]}
+	if !needsObjectLabels(all) {
 		return res, nil
 	}
 
-	for _, el := range all.Items {
-		serviceName, _, err := unstructured.NestedString(el.Object, "spec", "serviceName")
-		if err != nil {
-			klog.ErrorS(err, "unable to read 'serviceName' attribute",
-				"registration", el.GetName())
+	var err error
+	res.labels, err = findObjectLabels(c.resolveObject, &evt.InvolvedObject)
+	return res, err
+}
+
+func (c *pusher) notifyAll(all []*registration, evt corev1.Event, in *filterInput) {
+	for _, el := range all {
+		correlated := len(in.compositionId) > 0
+		if !el.acceptCompositionID(in.compositionId) {
+			metrics.CompositionIDPolicyApplied(el.name, el.compositionIDPolicy(), correlated, false)
+			klog.V(4).InfoS("event dropped by the composition id policy",
+				"registration", el.name,
+				"policy", el.compositionIDPolicy(),
+				"name", evt.Name,
+				"reason", evt.Reason)
 			continue
 		}
+		metrics.CompositionIDPolicyApplied(el.name, el.compositionIDPolicy(), correlated, true)
 
-		endpoint, _, err := unstructured.NestedString(el.Object, "spec", "endpoint")
+		ok, err := el.accept(in)
 		if err != nil {
-			klog.ErrorS(err, "unable to read 'endpoint' attribute",
-				"registration", el.GetName())
+			klog.ErrorS(err, "unable to evaluate registration filter",
+				"registration", el.name, "involvedObject", evt.InvolvedObject.Name)
 			continue
 		}
 
-		res[el.GetName()] = v1alpha1.RegistrationSpec{
-			ServiceName: serviceName,
-			Endpoint:    endpoint,
+		if !ok {
+			klog.V(4).InfoS("event filtered out",
+				"registration", el.name,
+				"name", evt.Name,
+				"reason", evt.Reason)
+			continue
 		}
-	}
 
-	return res, nil
+		if c.watermarks.handled(el, &evt) {
+			klog.V(2).InfoS("event already handled before the restart",
+				"registration", el.name,
+				"name", evt.Name,
+				"reason", evt.Reason)
+			continue
+		}
+		c.watermarks.begin(el.name, &evt)
+
+		job := newAdvisor(advOpts{
+			clients:          c.clients,
+			registrationName: el.name,
+			registrationSpec: el.spec,
+			template:         el.template,
+			eventInfo:        evt,
+			objectLabels:     in.objectLabels,
+			backoff:          c.backoff,
+			deadLetter:       c.deadLetter,
+			recorder:         c.recorder,
+			refs:             c.refs,
+			watermarks:       c.watermarks,
+			retrier:          c.retrier,
+		})
+
+		c.notifyQueue.Push(job)
+	}
 }
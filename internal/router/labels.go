@@ -2,17 +2,26 @@ package router
 
 import (
 	"context"
+	"sort"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/krateoplatformops/eventrouter/internal/metrics"
 	"github.com/krateoplatformops/eventrouter/internal/objects"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 )
 
 const (
 	keyCompositionID = "krateo.io/composition-id"
+	// keyCompositionSource annotates the notified events with the
+	// object supplying the composition id (i.e. an owner of the
+	// involved object), as '/apis/apps/v1/namespaces/demo/deployment/web'
+	keyCompositionSource = "krateo.io/composition-source"
 )
 
 func hasCompositionId(obj *corev1.Event) bool {
@@ -28,44 +37,188 @@ func hasCompositionId(obj *corev1.Event) bool {
 	return ok
 }
 
-func findCompositionID(resolver *objects.ObjectResolver, ref *corev1.ObjectReference) (cid string, err error) {
-	var obj *unstructured.Unstructured
+// resolution is the outcome of a composition id resolution.
+type resolution struct {
+	compositionId string
+	// correlations are the correlation keys found on the source
+	correlations map[string]string
+	// labels are the ones found on the involved object
+	labels map[string]string
+	// source is the object supplying the composition id,
+	// the involved object or one of its owners
+	source *corev1.ObjectReference
+}
 
-	retryErr := retry.OnError(retry.DefaultRetry,
-		func(e error) bool {
-			if e != nil {
-				resolver.InvalidateRESTMapperCache()
-				return true
-			}
-			return false
-		},
-		func() error {
-			obj, err = resolver.ResolveReference(context.Background(), ref)
-			return err
-		})
-	if retryErr != nil {
-		return "", retryErr
+// resolveFunc returns the referenced object, nil if not found.
+type resolveFunc func(ref *corev1.ObjectReference) (*unstructured.Unstructured, error)
+
+// findCompositionID resolves the referenced object returning the composition id, the
+// value of the first correlation key found, and all the labels found on it; when the
+// object has no correlation key, its owners are walked up to maxDepth levels looking
+// for a correlated ancestor.
+func findCompositionID(resolve resolveFunc, ref *corev1.ObjectReference, keys []CorrelationKey, maxDepth int) (res resolution, err error) {
+	start := time.Now()
+	defer func() {
+		result := metrics.ResolutionFound
+		switch {
+		case err != nil:
+			result = metrics.ResolutionError
+		case len(res.compositionId) == 0:
+			result = metrics.ResolutionNotFound
+		}
+		metrics.CompositionIDResolved(result, time.Since(start))
+	}()
+
+	return walkOwners(resolve, ref, keys, maxDepth)
+}
+
+// findObjectLabels resolves the referenced object returning its labels.
+func findObjectLabels(resolve resolveFunc, ref *corev1.ObjectReference) (map[string]string, error) {
+	res, err := walkOwners(resolve, ref, nil, 0)
+	return res.labels, err
+}
+
+// resolveWithRetry resolves the references, unless cached, retrying
+// with a fresh RESTMapper when the resolution fails.
+func resolveWithRetry(resolver *objects.ObjectResolver, objCache *objects.MetadataCache) resolveFunc {
+	return func(ref *corev1.ObjectReference) (*unstructured.Unstructured, error) {
+		if obj, ok := objCache.Get(ref); ok {
+			return obj, nil
+		}
+
+		var obj *unstructured.Unstructured
+		err := retry.OnError(retry.DefaultRetry,
+			func(e error) bool {
+				if e != nil {
+					resolver.InvalidateRESTMapperCache()
+					return true
+				}
+				return false
+			},
+			func() (err error) {
+				obj, err = resolver.ResolveReference(context.Background(), ref)
+				return err
+			})
+		if obj != nil {
+			objCache.Add(obj)
+		}
+		return obj, err
 	}
+}
 
-	if obj == nil {
-		klog.V(4).InfoS("object not found resolving reference",
-			"name", ref.Name,
-			"kind", ref.Kind,
-			"apiVersion", ref.APIVersion)
-		return "", nil
+// walkOwners visits breadth first the referenced object and its owners, the controller
+// first, until one with a correlation key is found; every object is visited once,
+// so that ownership cycles are not walked forever.
+func walkOwners(resolve resolveFunc, ref *corev1.ObjectReference, keys []CorrelationKey, maxDepth int) (resolution, error) {
+	type step struct {
+		ref   *corev1.ObjectReference
+		depth int
 	}
 
-	labels := obj.GetLabels()
-	if len(labels) == 0 {
+	res := resolution{}
+	visited := map[types.UID]bool{}
+	queue := []step{{ref: ref}}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		obj, err := resolve(cur.ref)
+		if err != nil {
+			// the involved object must be resolved, its owners may not be readable
+			if cur.depth == 0 {
+				return resolution{}, err
+			}
+			klog.V(4).ErrorS(err, "unable to resolve owner reference",
+				"name", cur.ref.Name,
+				"kind", cur.ref.Kind,
+				"apiVersion", cur.ref.APIVersion,
+				"involvedObject", ref.Name)
+			continue
+		}
+
+		if obj == nil {
+			klog.V(4).InfoS("object not found resolving reference",
+				"name", cur.ref.Name,
+				"kind", cur.ref.Kind,
+				"apiVersion", cur.ref.APIVersion)
+			continue
+		}
+		visited[obj.GetUID()] = true
+
+		labels := obj.GetLabels()
+		if cur.depth == 0 {
+			res.labels = labels
+			klog.V(4).InfoS("labels found in resolved reference",
+				"labels", spew.Sdump(labels))
+		}
+
+		if cid, correlations := correlate(obj, keys); len(cid) > 0 {
+			res.compositionId = cid
+			res.correlations = correlations
+			res.source = referenceOf(obj)
+			if cur.depth > 0 {
+				klog.V(4).InfoS("composition id found on owner",
+					"owner", cloudEventSource(res.source),
+					"depth", cur.depth,
+					"involvedObject", ref.Name)
+			}
+			return res, nil
+		}
+
+		if cur.depth >= maxDepth {
+			continue
+		}
+
+		for _, el := range ownersOf(obj) {
+			if visited[el.UID] {
+				continue
+			}
+			visited[el.UID] = true
+
+			queue = append(queue, step{
+				ref: &corev1.ObjectReference{
+					APIVersion: el.APIVersion,
+					Kind:       el.Kind,
+					Name:       el.Name,
+					UID:        el.UID,
+					// owners are in the same namespace or cluster scoped
+					Namespace: obj.GetNamespace(),
+				},
+				depth: cur.depth + 1,
+			})
+		}
+	}
+
+	if len(res.labels) == 0 {
 		klog.V(4).InfoS("no labels found in resolved reference",
 			"name", ref.Name,
 			"kind", ref.Kind,
 			"apiVersion", ref.APIVersion)
-		return "", nil
 	}
 
-	klog.V(4).InfoS("labels found in resolved reference",
-		"labels", spew.Sdump(labels))
+	return res, nil
+}
+
+// ownersOf returns the owner references of the object, the controller first.
+func ownersOf(obj *unstructured.Unstructured) []metav1.OwnerReference {
+	res := obj.GetOwnerReferences()
+	sort.SliceStable(res, func(i, j int) bool {
+		return isController(res[i]) && !isController(res[j])
+	})
+	return res
+}
+
+func isController(ref metav1.OwnerReference) bool {
+	return ref.Controller != nil && *ref.Controller
+}
 
-	return labels[keyCompositionID], nil
+func referenceOf(obj *unstructured.Unstructured) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		UID:        obj.GetUID(),
+	}
 }
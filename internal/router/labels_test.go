@@ -0,0 +1,115 @@
+package router
+
+import (
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type fakeObjects map[string]*unstructured.Unstructured
+
+func (f fakeObjects) add(kind, name string, labels map[string]string, owners ...metav1.OwnerReference) {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("apps/v1")
+	obj.SetKind(kind)
+	obj.SetNamespace("demo")
+	obj.SetName(name)
+	obj.SetUID(types.UID(kind + "-" + name))
+	obj.SetLabels(labels)
+	obj.SetOwnerReferences(owners)
+	f[kind+"/"+name] = obj
+}
+
+func (f fakeObjects) resolve(visits *[]string) resolveFunc {
+	return func(ref *corev1.ObjectReference) (*unstructured.Unstructured, error) {
+		*visits = append(*visits, ref.Kind+"/"+ref.Name)
+		if ref.Kind == "Forbidden" {
+			return nil, errors.New("forbidden")
+		}
+		return f[ref.Kind+"/"+ref.Name], nil
+	}
+}
+
+func owner(kind, name string, controller bool) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: "apps/v1",
+		Kind:       kind,
+		Name:       name,
+		UID:        types.UID(kind + "-" + name),
+		Controller: &controller,
+	}
+}
+
+func TestWalkOwners(t *testing.T) {
+	objs := fakeObjects{}
+	objs.add("Deployment", "web", map[string]string{keyCompositionID: "1234"})
+	objs.add("ReplicaSet", "web-5d4f", nil, owner("Forbidden", "x", false), owner("Deployment", "web", true))
+	objs.add("Pod", "web-5d4f-abcd", map[string]string{"app": "web"}, owner("ReplicaSet", "web-5d4f", true))
+	// ownership cycle
+	objs.add("Pod", "a", nil, owner("Pod", "b", true))
+	objs.add("Pod", "b", nil, owner("Pod", "a", true))
+
+	table := []struct {
+		name     string
+		ref      string
+		maxDepth int
+		wantCID  string
+		wantSrc  string
+		visits   int
+	}{
+		{name: "labelled object", ref: "Deployment/web", maxDepth: 5, wantCID: "1234", wantSrc: "/apis/apps/v1/namespaces/demo/deployment/web", visits: 1},
+		{name: "labelled ancestor", ref: "Pod/web-5d4f-abcd", maxDepth: 5, wantCID: "1234", wantSrc: "/apis/apps/v1/namespaces/demo/deployment/web", visits: 3},
+		{name: "depth limit", ref: "Pod/web-5d4f-abcd", maxDepth: 1, visits: 2},
+		{name: "walk disabled", ref: "Pod/web-5d4f-abcd", maxDepth: 0, visits: 1},
+		{name: "cycle", ref: "Pod/a", maxDepth: 10, visits: 2},
+		{name: "not found", ref: "Pod/missing", maxDepth: 5, visits: 1},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			obj := objs[tc.ref]
+			ref := &corev1.ObjectReference{Kind: "Pod", Name: "missing"}
+			if obj != nil {
+				ref = referenceOf(obj)
+			}
+
+			visits := []string{}
+			res, err := walkOwners(objs.resolve(&visits), ref, DefaultCorrelationKeys, tc.maxDepth)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.compositionId != tc.wantCID {
+				t.Fatalf("got composition id: %q, expected: %q", res.compositionId, tc.wantCID)
+			}
+
+			src := ""
+			if res.source != nil {
+				src = cloudEventSource(res.source)
+			}
+			if src != tc.wantSrc {
+				t.Fatalf("got source: %q, expected: %q", src, tc.wantSrc)
+			}
+
+			if len(visits) != tc.visits {
+				t.Fatalf("got visits: %v, expected %d", visits, tc.visits)
+			}
+
+			if obj != nil && len(res.labels) != len(obj.GetLabels()) {
+				t.Fatalf("expected the involved object labels, got %v", res.labels)
+			}
+		})
+	}
+}
+
+func TestWalkOwnersError(t *testing.T) {
+	_, err := walkOwners(fakeObjects{}.resolve(&[]string{}),
+		&corev1.ObjectReference{Kind: "Forbidden", Name: "x"}, DefaultCorrelationKeys, 5)
+	if err == nil {
+		t.Fatal("expected the involved object resolution error")
+	}
+}
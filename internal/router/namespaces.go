@@ -0,0 +1,245 @@
+package router
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krateoplatformops/eventrouter/internal/events"
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// ParseNamespaces splits a comma separated list of namespaces.
+func ParseNamespaces(s string) []string {
+	res := []string{}
+	for _, ns := range strings.Split(s, ",") {
+		if ns = strings.TrimSpace(ns); len(ns) > 0 {
+			res = append(res, ns)
+		}
+	}
+	return res
+}
+
+// nsWatcher holds the events informers of a namespace.
+type nsWatcher struct {
+	informers []cache.SharedInformer
+	done      chan struct{}
+	once      sync.Once
+
+	// known are the events received by the replaced watcher,
+	// not handled again when relisted
+	knownMu sync.Mutex
+	known   map[string]struct{}
+	// since, if set, is when the watcher replaced the previous one,
+	// the events happened before it and not received are historic
+	since time.Time
+}
+
+// start runs the informers until stopped or the stop channel is closed.
+func (w *nsWatcher) start(stopCh <-chan struct{}) {
+	for _, inf := range w.informers {
+		go inf.Run(w.done)
+	}
+
+	go func() {
+		select {
+		case <-stopCh:
+			w.stop()
+		case <-w.done:
+		}
+	}()
+}
+
+func (w *nsWatcher) stop() {
+	w.once.Do(func() { close(w.done) })
+}
+
+// keys returns the keys of the cached events.
+func (w *nsWatcher) keys() map[string]struct{} {
+	res := map[string]struct{}{}
+	for _, inf := range w.informers {
+		for _, obj := range inf.GetStore().List() {
+			if key, ok := eventKey(obj); ok {
+				res[key] = struct{}{}
+			}
+		}
+	}
+	return res
+}
+
+// relisted reports whether the event has already been received by the replaced watcher
+// or, not received because excluded by its selector, happened before the replacement.
+func (w *nsWatcher) relisted(obj interface{}) bool {
+	w.knownMu.Lock()
+	defer w.knownMu.Unlock()
+
+	if len(w.known) == 0 && w.since.IsZero() {
+		return false
+	}
+
+	if key, ok := eventKey(obj); ok {
+		if _, ok := w.known[key]; ok {
+			delete(w.known, key)
+			return true
+		}
+	}
+
+	if w.since.IsZero() {
+		return false
+	}
+
+	evt, ok := events.Normalize(obj)
+	return ok && eventTime(evt).Before(w.since)
+}
+
+// eventKey is the events.Key of a core/v1 or events.k8s.io/v1 Event.
+func eventKey(obj interface{}) (string, bool) {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return "", false
+	}
+	return string(m.GetUID()) + "." + m.GetResourceVersion(), true
+}
+
+// newWatcher creates the informers of the namespace for the watched Events APIs.
+func (er *EventRouter) newWatcher(namespace string) *nsWatcher {
+	res := &nsWatcher{done: make(chan struct{})}
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if !res.relisted(obj) {
+				er.OnAdd(obj)
+			}
+		},
+		UpdateFunc: er.OnUpdate,
+		DeleteFunc: er.OnDelete,
+	}
+
+	add := func(client rest.Interface, obj runtime.Object, api events.API) {
+		sel, err := events.FieldSelector(er.fieldSelector, api)
+		if err != nil {
+			klog.ErrorS(err, "unable to translate the field selector, watching all the events",
+				"fieldSelector", er.fieldSelector.String(), "api", api)
+			sel = fields.Everything()
+		}
+
+		lw := cache.NewListWatchFromClient(client, "events", namespace, sel)
+		inf := cache.NewSharedInformer(lw, obj, er.resyncInterval)
+		inf.AddEventHandler(handler)
+		res.informers = append(res.informers, inf)
+	}
+
+	if er.api == events.APICore || er.api == events.APIBoth {
+		add(er.restClient, &corev1.Event{}, events.APICore)
+	}
+
+	if er.api == events.APIEvents || er.api == events.APIBoth {
+		add(er.eventsRESTClient, &eventsv1.Event{}, events.APIEvents)
+	}
+
+	return res
+}
+
+// informers returns the events informers of all the watched namespaces.
+func (er *EventRouter) informers() []cache.SharedInformer {
+	er.watchersMu.RLock()
+	defer er.watchersMu.RUnlock()
+
+	res := []cache.SharedInformer{}
+	for _, w := range er.watchers {
+		res = append(res, w.informers...)
+	}
+	return res
+}
+
+// newNamespaceInformer watches the namespaces matching the selector; the API server
+// notifies a deletion when a namespace is relabelled and does not match anymore.
+func newNamespaceInformer(client rest.Interface, sel labels.Selector, resync time.Duration) cache.SharedInformer {
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = sel.String()
+			return client.Get().Resource("namespaces").
+				VersionedParams(&options, metav1.ParameterCodec).
+				Do(context.Background()).Get()
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = sel.String()
+			options.Watch = true
+			return client.Get().Resource("namespaces").
+				VersionedParams(&options, metav1.ParameterCodec).
+				Watch(context.Background())
+		},
+	}
+
+	return cache.NewSharedInformer(lw, &corev1.Namespace{}, resync)
+}
+
+// onNamespace starts watching the events of a selected namespace; unless listed at startup,
+// the events happened before (i.e. while the namespace was not selected) are not handled.
+func (er *EventRouter) onNamespace(obj interface{}, isInInitialList bool) {
+	ns, ok := obj.(*corev1.Namespace)
+	if !ok {
+		return
+	}
+
+	if !er.nsSelector.Matches(labels.Set(ns.Labels)) {
+		er.onNamespaceDelete(obj)
+		return
+	}
+
+	er.watchersMu.Lock()
+	defer er.watchersMu.Unlock()
+
+	if _, ok := er.watchers[ns.Name]; ok {
+		return
+	}
+
+	// before Run the watcher is started along with the others
+	w := er.newWatcher(ns.Name)
+	if !isInInitialList {
+		w.since = time.Now()
+	}
+	if er.stopCh != nil {
+		w.start(er.stopCh)
+	}
+	er.watchers[ns.Name] = w
+
+	klog.InfoS("watching namespace events", "namespace", ns.Name)
+}
+
+// onNamespaceDelete stops watching the events of a namespace no more selected,
+// unless explicitly watched.
+func (er *EventRouter) onNamespaceDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	ns, ok := obj.(*corev1.Namespace)
+	if !ok || er.namespaces[ns.Name] {
+		return
+	}
+
+	er.watchersMu.Lock()
+	defer er.watchersMu.Unlock()
+
+	w, ok := er.watchers[ns.Name]
+	if !ok {
+		return
+	}
+
+	w.stop()
+	delete(er.watchers, ns.Name)
+
+	klog.InfoS("stopped watching namespace events", "namespace", ns.Name)
+}
@@ -0,0 +1,90 @@
+package router
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestParseNamespaces(t *testing.T) {
+	table := []struct {
+		in   string
+		want []string
+	}{
+		{in: "", want: []string{}},
+		{in: "default", want: []string{"default"}},
+		{in: " demo, prod ,,", want: []string{"demo", "prod"}},
+	}
+
+	for i, tc := range table {
+		got := ParseNamespaces(tc.in)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("[tc: %d] - got: %v, expected: %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestEventRouterNamespaces(t *testing.T) {
+	er := NewEventRouter(EventRouterOpts{
+		Handler:           &recordingHandler{},
+		Namespaces:        []string{"static"},
+		NamespaceSelector: labels.SelectorFromSet(labels.Set{"eventrouter": "enabled"}),
+	})
+
+	watched := func() []string {
+		er.watchersMu.RLock()
+		defer er.watchersMu.RUnlock()
+
+		res := []string{}
+		for _, ns := range []string{"", "static", "demo", "other"} {
+			if _, ok := er.watchers[ns]; ok {
+				res = append(res, ns)
+			}
+		}
+		return res
+	}
+
+	if got := watched(); !reflect.DeepEqual(got, []string{"static"}) {
+		t.Fatalf("expected only the static namespace watched, got %v", got)
+	}
+
+	namespace := func(name string, lbls map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: lbls}}
+	}
+
+	er.onNamespace(namespace("demo", map[string]string{"eventrouter": "enabled"}), true)
+	er.onNamespace(namespace("other", nil), true)
+	if got := watched(); !reflect.DeepEqual(got, []string{"static", "demo"}) {
+		t.Fatalf("expected the selected namespace watched, got %v", got)
+	}
+	if !er.watchers["demo"].since.IsZero() {
+		t.Fatal("expected all the events of a namespace listed at startup handled")
+	}
+
+	// relabelled
+	er.onNamespace(namespace("demo", map[string]string{"eventrouter": "disabled"}), false)
+	if got := watched(); !reflect.DeepEqual(got, []string{"static"}) {
+		t.Fatalf("expected the relabelled namespace not watched, got %v", got)
+	}
+
+	// relabelled back, its history is not replayed
+	er.onNamespace(namespace("demo", map[string]string{"eventrouter": "enabled"}), false)
+	historic := &corev1.Event{
+		ObjectMeta:    metav1.ObjectMeta{UID: "1", ResourceVersion: "1"},
+		LastTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+	}
+	if !er.watchers["demo"].relisted(historic) {
+		t.Fatal("expected the events happened before the namespace was selected again not handled")
+	}
+
+	er.onNamespaceDelete(cache.DeletedFinalStateUnknown{Obj: namespace("demo", nil)})
+	er.onNamespaceDelete(namespace("static", nil))
+	if got := watched(); !reflect.DeepEqual(got, []string{"static"}) {
+		t.Fatalf("expected only the static namespace watched after the deletions, got %v", got)
+	}
+}
@@ -0,0 +1,109 @@
+package router
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+type NamespaceLabelsOpts struct {
+	RESTConfig *rest.Config
+	// Client, if set, is used instead of a client for RESTConfig
+	Client         kubernetes.Interface
+	ResyncInterval time.Duration
+}
+
+// NewNamespaceLabels creates a lookup of the namespaces labels, served by
+// an informer on all the namespaces started at the first lookup.
+func NewNamespaceLabels(opts NamespaceLabelsOpts) (*NamespaceLabels, error) {
+	client := opts.Client
+	if client == nil {
+		clientSet, err := kubernetes.NewForConfig(opts.RESTConfig)
+		if err != nil {
+			return nil, err
+		}
+		client = clientSet
+	}
+
+	return &NamespaceLabels{
+		client:         client,
+		resyncInterval: opts.ResyncInterval,
+		stop:           make(chan struct{}),
+	}, nil
+}
+
+// NamespaceLabels resolves the labels of the events namespaces, so that
+// the registrations namespace selectors don't hit the API server per event.
+type NamespaceLabels struct {
+	client         kubernetes.Interface
+	resyncInterval time.Duration
+
+	once     sync.Once
+	informer cache.SharedInformer
+	stop     chan struct{}
+}
+
+// Run blocks until the stop channel is closed, then stops the informer.
+func (n *NamespaceLabels) Run(stopCh <-chan struct{}) {
+	<-stopCh
+	close(n.stop)
+}
+
+// Get returns the labels of the namespace, nil if not found; until the informer
+// has synced, or for a namespace it has not received yet, they're read with a GET.
+func (n *NamespaceLabels) Get(name string) (labels.Set, error) {
+	n.once.Do(n.start)
+
+	if n.informer.HasSynced() {
+		obj, ok, err := n.informer.GetStore().GetByKey(name)
+		if err == nil && ok {
+			return obj.(*corev1.Namespace).Labels, nil
+		}
+	}
+
+	var ns *corev1.Namespace
+	err := retry.OnError(retry.DefaultRetry,
+		func(e error) bool {
+			return !apierrors.IsNotFound(e) && !apierrors.IsForbidden(e)
+		},
+		func() (err error) {
+			ns, err = n.client.CoreV1().Namespaces().Get(context.Background(), name, metav1.GetOptions{})
+			return err
+		})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ns.Labels, nil
+}
+
+func (n *NamespaceLabels) start() {
+	cli := n.client.CoreV1().Namespaces()
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return cli.List(context.Background(), opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			return cli.Watch(context.Background(), opts)
+		},
+	}
+
+	n.informer = cache.NewSharedInformer(lw, &corev1.Namespace{}, n.resyncInterval)
+	go n.informer.Run(n.stop)
+
+	klog.V(4).InfoS("watching namespaces for the registrations namespace selectors")
+}
@@ -0,0 +1,61 @@
+package router
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestNamespaceLabels(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-system", Labels: map[string]string{"tenant": "acme"}},
+	})
+
+	gets := 0
+	client.PrependReactor("get", "namespaces", func(k8stesting.Action) (bool, runtime.Object, error) {
+		gets++
+		return false, nil, nil
+	})
+
+	lookup, err := NewNamespaceLabels(NamespaceLabelsOpts{Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	go lookup.Run(stop)
+	t.Cleanup(func() { close(stop) })
+
+	got, err := lookup.Get("demo-system")
+	if err != nil || got["tenant"] != "acme" {
+		t.Fatalf("unexpected labels: %v (%v)", got, err)
+	}
+
+	got, err = lookup.Get("missing")
+	if err != nil || got != nil {
+		t.Fatalf("expected no labels for a missing namespace, got: %v (%v)", got, err)
+	}
+
+	// once synced, the lookups are served by the informer
+	if err := wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 5*time.Second, true,
+		func(context.Context) (bool, error) { return lookup.informer.HasSynced(), nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	gets = 0
+	for i := 0; i < 3; i++ {
+		if got, _ := lookup.Get("demo-system"); got["tenant"] != "acme" {
+			t.Fatalf("unexpected labels: %v", got)
+		}
+	}
+	if gets != 0 {
+		t.Errorf("expected no API server reads, got: %d", gets)
+	}
+}
@@ -0,0 +1,201 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsTypePrefix  = "io.krateo.eventrouter"
+
+	contentTypeJSON        = "application/json"
+	contentTypeCloudEvents = "application/cloudevents+json"
+)
+
+// payload is an encoded notification body along with its headers.
+type payload struct {
+	body   []byte
+	header http.Header
+}
+
+// encodePayload encodes the event in the registration format;
+// when a template is specified it renders the payload body
+// (or the event data in the 'cloudevents' format).
+func encodePayload(reg *v1alpha1.RegistrationSpec, tpl *template.Template, data *templateData) (*payload, error) {
+	evt := data.Event
+
+	dat, contentType, err := encodeData(reg, tpl, data)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &payload{
+		body:   dat,
+		header: http.Header{},
+	}
+	res.header.Set("Content-Type", contentType)
+
+	switch reg.Format {
+	case "", v1alpha1.FormatRaw:
+		return res, nil
+	case v1alpha1.FormatCloudEvents:
+	default:
+		return nil, fmt.Errorf("unknown payload format '%s'", reg.Format)
+	}
+
+	mode := v1alpha1.CloudEventsModeBinary
+	if reg.CloudEvents != nil && len(reg.CloudEvents.Mode) > 0 {
+		mode = reg.CloudEvents.Mode
+	}
+
+	attrs := cloudEventAttributes(evt, data.CompositionID)
+
+	switch mode {
+	case v1alpha1.CloudEventsModeBinary:
+		for k, v := range attrs {
+			res.header.Set("ce-"+k, v)
+		}
+		return res, nil
+
+	case v1alpha1.CloudEventsModeStructured:
+		envelope := map[string]any{
+			"datacontenttype": contentType,
+		}
+		if isJSON(contentType) && json.Valid(dat) {
+			envelope["data"] = json.RawMessage(dat)
+		} else {
+			envelope["data"] = string(dat)
+		}
+		for k, v := range attrs {
+			envelope[k] = v
+		}
+
+		res.body, err = json.Marshal(envelope)
+		if err != nil {
+			return nil, err
+		}
+		res.header.Set("Content-Type", contentTypeCloudEvents)
+		return res, nil
+	}
+
+	return nil, fmt.Errorf("unknown cloudevents mode '%s'", mode)
+}
+
+// encodeData returns the JSON encoded event or, if any, the rendered template.
+func encodeData(reg *v1alpha1.RegistrationSpec, tpl *template.Template, data *templateData) ([]byte, string, error) {
+	if tpl == nil {
+		dat, err := json.Marshal(data.Event)
+		return dat, contentTypeJSON, err
+	}
+
+	dat, err := renderTemplate(tpl, data)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot render template: %w", err)
+	}
+
+	contentType := contentTypeJSON
+	if reg.Template != nil && len(reg.Template.ContentType) > 0 {
+		contentType = reg.Template.ContentType
+	}
+
+	return dat, contentType, nil
+}
+
+// isJSON reports whether the media type is JSON (i.e. 'application/json', 'application/problem+json').
+func isJSON(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mt == contentTypeJSON || strings.HasSuffix(mt, "+json")
+}
+
+// cloudEventAttributes derives the CloudEvents context attributes from the event.
+func cloudEventAttributes(evt *corev1.Event, compositionId string) map[string]string {
+	res := map[string]string{
+		"specversion": cloudEventsSpecVersion,
+		"id":          cloudEventID(evt),
+		"source":      cloudEventSource(&evt.InvolvedObject),
+		"type":        cloudEventType(evt),
+	}
+
+	if len(compositionId) > 0 {
+		res["subject"] = compositionId
+	}
+
+	if ts := eventTime(evt); !ts.IsZero() {
+		res["time"] = ts.UTC().Format(time.RFC3339Nano)
+	}
+
+	return res
+}
+
+// cloudEventID is unique for every update of the event.
+func cloudEventID(evt *corev1.Event) string {
+	if len(evt.ResourceVersion) == 0 {
+		return string(evt.UID)
+	}
+	return string(evt.UID) + "." + evt.ResourceVersion
+}
+
+// cloudEventType is i.e. 'io.krateo.eventrouter.warning.CannotCreateExternalResource'.
+func cloudEventType(evt *corev1.Event) string {
+	parts := []string{cloudEventsTypePrefix}
+	if len(evt.Type) > 0 {
+		parts = append(parts, strings.ToLower(evt.Type))
+	}
+	if len(evt.Reason) > 0 {
+		parts = append(parts, evt.Reason)
+	}
+	return strings.Join(parts, ".")
+}
+
+// cloudEventSource is the involved object path,
+// i.e. '/apis/eks.aws.crossplane.io/v1alpha1/nodegroup/test-1-ng'.
+func cloudEventSource(ref *corev1.ObjectReference) string {
+	var sb strings.Builder
+
+	gv := ref.GroupVersionKind().GroupVersion()
+	if len(gv.Group) == 0 {
+		sb.WriteString("/api/")
+	} else {
+		sb.WriteString("/apis/")
+		sb.WriteString(gv.Group)
+		sb.WriteString("/")
+	}
+	sb.WriteString(gv.Version)
+
+	if len(ref.Namespace) > 0 {
+		sb.WriteString("/namespaces/")
+		sb.WriteString(ref.Namespace)
+	}
+
+	sb.WriteString("/")
+	sb.WriteString(strings.ToLower(ref.Kind))
+	sb.WriteString("/")
+	sb.WriteString(ref.Name)
+
+	return sb.String()
+}
+
+// eventTime returns the most recent time recorded on the event.
+func eventTime(evt *corev1.Event) time.Time {
+	switch {
+	case !evt.LastTimestamp.IsZero():
+		return evt.LastTimestamp.Time
+	case !evt.EventTime.IsZero():
+		return evt.EventTime.Time
+	case !evt.FirstTimestamp.IsZero():
+		return evt.FirstTimestamp.Time
+	}
+	return evt.CreationTimestamp.Time
+}
@@ -0,0 +1,157 @@
+package router
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func samplePayloadEvent() *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test-1-ng.170c791ccd13d0cd",
+			Namespace:       "default",
+			UID:             "6aa0a50b-1b5b-46e0-b5ec-a1118286f0c4",
+			ResourceVersion: "17223",
+		},
+		Type:          corev1.EventTypeWarning,
+		Reason:        "CannotCreateExternalResource",
+		LastTimestamp: metav1.NewTime(time.Date(2022, 10, 26, 15, 25, 12, 0, time.UTC)),
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "eks.aws.crossplane.io/v1alpha1",
+			Kind:       "NodeGroup",
+			Name:       "test-1-ng",
+		},
+	}
+}
+
+func TestEncodePayloadRaw(t *testing.T) {
+	evt := samplePayloadEvent()
+
+	pay, err := encodePayload(&v1alpha1.RegistrationSpec{}, nil,
+		newTemplateData(evt, "abcde12345", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := pay.header.Get("Content-Type"); got != contentTypeJSON {
+		t.Errorf("unexpected content type: %s", got)
+	}
+
+	var got corev1.Event
+	if err := json.Unmarshal(pay.body, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Reason != evt.Reason {
+		t.Errorf("unexpected body: %s", pay.body)
+	}
+}
+
+func TestEncodePayloadCloudEventsBinary(t *testing.T) {
+	pay, err := encodePayload(&v1alpha1.RegistrationSpec{
+		Format: v1alpha1.FormatCloudEvents,
+	}, nil, newTemplateData(samplePayloadEvent(), "abcde12345", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"Content-Type":   contentTypeJSON,
+		"Ce-Specversion": "1.0",
+		"Ce-Id":          "6aa0a50b-1b5b-46e0-b5ec-a1118286f0c4.17223",
+		"Ce-Type":        "io.krateo.eventrouter.warning.CannotCreateExternalResource",
+		"Ce-Source":      "/apis/eks.aws.crossplane.io/v1alpha1/nodegroup/test-1-ng",
+		"Ce-Subject":     "abcde12345",
+		"Ce-Time":        "2022-10-26T15:25:12Z",
+	}
+
+	for k, v := range want {
+		if got := pay.header.Get(k); got != v {
+			t.Errorf("%s: expected %q, got %q", k, v, got)
+		}
+	}
+}
+
+func TestEncodePayloadCloudEventsStructured(t *testing.T) {
+	pay, err := encodePayload(&v1alpha1.RegistrationSpec{
+		Format: v1alpha1.FormatCloudEvents,
+		CloudEvents: &v1alpha1.CloudEventsSpec{
+			Mode: v1alpha1.CloudEventsModeStructured,
+		},
+	}, nil, newTemplateData(samplePayloadEvent(), "", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := pay.header.Get("Content-Type"); got != contentTypeCloudEvents {
+		t.Errorf("unexpected content type: %s", got)
+	}
+
+	var envelope struct {
+		SpecVersion string       `json:"specversion"`
+		Type        string       `json:"type"`
+		Subject     *string      `json:"subject"`
+		Data        corev1.Event `json:"data"`
+	}
+	if err := json.Unmarshal(pay.body, &envelope); err != nil {
+		t.Fatal(err)
+	}
+
+	if envelope.SpecVersion != "1.0" || envelope.Subject != nil ||
+		envelope.Data.Reason != "CannotCreateExternalResource" {
+		t.Errorf("unexpected envelope: %s", pay.body)
+	}
+}
+
+func TestEncodePayloadTemplate(t *testing.T) {
+	tpl, err := compileTemplate("test", `{{ .InvolvedObject.Kind }}/{{ .Event.Reason }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := &v1alpha1.RegistrationSpec{
+		Format: v1alpha1.FormatCloudEvents,
+		CloudEvents: &v1alpha1.CloudEventsSpec{
+			Mode: v1alpha1.CloudEventsModeStructured,
+		},
+		Template: &v1alpha1.PayloadTemplate{
+			ContentType: "text/plain",
+		},
+	}
+
+	pay, err := encodePayload(reg, tpl, newTemplateData(samplePayloadEvent(), "", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var envelope struct {
+		DataContentType string `json:"datacontenttype"`
+		Data            string `json:"data"`
+	}
+	if err := json.Unmarshal(pay.body, &envelope); err != nil {
+		t.Fatal(err)
+	}
+
+	if envelope.DataContentType != "text/plain" ||
+		envelope.Data != "NodeGroup/CannotCreateExternalResource" {
+		t.Errorf("unexpected envelope: %s", pay.body)
+	}
+}
+
+func TestCloudEventSourceCoreGroup(t *testing.T) {
+	got := cloudEventSource(&corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Service",
+		Namespace:  "demo-system",
+		Name:       "fake-service-1",
+	})
+
+	if want := "/api/v1/namespaces/demo-system/service/fake-service-1"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
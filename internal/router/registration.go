@@ -0,0 +1,156 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"text/template"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+	"github.com/krateoplatformops/eventrouter/internal/objects"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// registration is a loaded Registration along with its compiled
+// filter expression and payload template.
+type registration struct {
+	name     string
+	created  time.Time
+	spec     v1alpha1.RegistrationSpec
+	program  cel.Program
+	template *template.Template
+}
+
+// accept reports whether the event must be delivered to this registration.
+func (r *registration) accept(in *filterInput) (bool, error) {
+	ok, err := matchFilter(r.spec.Filter, in)
+	if !ok || err != nil {
+		return false, err
+	}
+
+	if r.program == nil {
+		return true, nil
+	}
+
+	return evalExpression(r.program, in.evt, in.compositionId)
+}
+
+// compileRegistration compiles the filter expression and the payload template, reusing
+// the ones of the previously loaded version when unchanged; it returns the registration
+// (nil if invalid) along with the Ready condition reflecting the compilation outcome.
+func compileRegistration(reg *v1alpha1.Registration, old *registration) (*registration, metav1.Condition) {
+	res := &registration{
+		name:    reg.Name,
+		created: reg.CreationTimestamp.Time,
+		spec:    reg.Spec,
+	}
+
+	notReady := func(reason string, err error) (*registration, metav1.Condition) {
+		return nil, metav1.Condition{
+			Type:               v1alpha1.TypeReady,
+			Status:             metav1.ConditionFalse,
+			Reason:             reason,
+			Message:            err.Error(),
+			ObservedGeneration: reg.Generation,
+		}
+	}
+
+	var err error
+	if expr := reg.Spec.FilterExpression; old != nil && old.spec.FilterExpression == expr {
+		res.program = old.program
+	} else if len(expr) > 0 {
+		res.program, err = compileExpression(expr)
+		if err != nil {
+			return notReady(v1alpha1.ReasonInvalidFilterExpression, err)
+		}
+	}
+
+	if tpl := reg.Spec.Template; old != nil && equality.Semantic.DeepEqual(old.spec.Template, tpl) {
+		res.template = old.template
+	} else if tpl != nil {
+		res.template, err = compileTemplate(reg.Name, tpl.Body)
+		if err != nil {
+			return notReady(v1alpha1.ReasonInvalidTemplate, err)
+		}
+	}
+
+	return res, metav1.Condition{
+		Type:               v1alpha1.TypeReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             v1alpha1.ReasonAvailable,
+		ObservedGeneration: reg.Generation,
+	}
+}
+
+// setCondition patches the registration status only if the condition has changed.
+func setCondition(ctx context.Context, resolver *objects.ObjectResolver, reg *v1alpha1.Registration, cond metav1.Condition) error {
+	status := *reg.Status.DeepCopy()
+	if !meta.SetStatusCondition(&status.Conditions, cond) {
+		return nil
+	}
+
+	return patchStatus(ctx, resolver, reg.Name, reg.ResourceVersion, status)
+}
+
+// patchStatus replaces the registration status, failing with a conflict
+// if the registration has been updated since the given resource version.
+func patchStatus(ctx context.Context, resolver *objects.ObjectResolver, name, resourceVersion string, status v1alpha1.RegistrationStatus) error {
+	dat, err := statusPatch(resourceVersion, status)
+	if err != nil {
+		return err
+	}
+
+	return resolver.Patch(ctx, objects.PatchOpts{
+		PatchData:   dat,
+		GVK:         v1alpha1.RegistrationGroupVersionKind,
+		Name:        name,
+		Subresource: "status",
+	})
+}
+
+// statusPatch is the merge patch body of the registration status; the counters are
+// always included, otherwise the zeroed ones would be kept at their previous value.
+func statusPatch(resourceVersion string, status v1alpha1.RegistrationStatus) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"resourceVersion": resourceVersion,
+		},
+		"status": status,
+	})
+}
+
+// acceptCompositionID reports whether the registration policy delivers
+// the events with the given composition id, empty if not found.
+func (r *registration) acceptCompositionID(compositionId string) bool {
+	switch r.compositionIDPolicy() {
+	case v1alpha1.CompositionIDOptional:
+		return true
+	case v1alpha1.CompositionIDOnlyUncorrelated:
+		return len(compositionId) == 0
+	}
+	return len(compositionId) > 0
+}
+
+func (r *registration) compositionIDPolicy() string {
+	if len(r.spec.CompositionIDPolicy) == 0 {
+		return v1alpha1.CompositionIDRequired
+	}
+	return r.spec.CompositionIDPolicy
+}
+
+// needsObjectLabels reports whether any registration filters the events by the
+// involved object labels or renders them in its template.
+func needsObjectLabels(all []*registration) bool {
+	for _, el := range all {
+		if f := el.spec.Filter; f != nil && f.ObjectSelector != nil {
+			return true
+		}
+		if el.template != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+)
+
+func TestRegistrationCompositionIDPolicy(t *testing.T) {
+	table := []struct {
+		policy       string
+		correlated   bool
+		uncorrelated bool
+	}{
+		{policy: "", correlated: true, uncorrelated: false},
+		{policy: v1alpha1.CompositionIDRequired, correlated: true, uncorrelated: false},
+		{policy: v1alpha1.CompositionIDOptional, correlated: true, uncorrelated: true},
+		{policy: v1alpha1.CompositionIDOnlyUncorrelated, correlated: false, uncorrelated: true},
+	}
+
+	for _, tc := range table {
+		reg := &registration{spec: v1alpha1.RegistrationSpec{CompositionIDPolicy: tc.policy}}
+
+		if got := reg.acceptCompositionID("1234"); got != tc.correlated {
+			t.Errorf("[policy: %q] - correlated events accepted: %v, expected: %v", tc.policy, got, tc.correlated)
+		}
+		if got := reg.acceptCompositionID(""); got != tc.uncorrelated {
+			t.Errorf("[policy: %q] - uncorrelated events accepted: %v, expected: %v", tc.policy, got, tc.uncorrelated)
+		}
+	}
+}
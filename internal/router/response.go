@@ -0,0 +1,112 @@
+package router
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxDrainBytes bounds the response body read before closing it,
+	// so that the connection can be reused by the pooled transport
+	maxDrainBytes = 64 << 10
+	// maxSnippetBytes bounds the response body reported in errors
+	maxSnippetBytes = 256
+)
+
+// Delivery failure reasons.
+const (
+	reasonConfig      = "configuration"
+	reasonRequest     = "request"
+	reasonTransport   = "transport"
+	reasonThrottled   = "throttled"
+	reasonServerError = "server_error"
+	reasonClientError = "client_error"
+	reasonUnexpected  = "unexpected_status"
+	reasonCancelled   = "cancelled"
+)
+
+// deliveryError describes a failed notification attempt.
+type deliveryError struct {
+	reason     string
+	statusCode int
+	retryable  bool
+	retryAfter time.Duration
+	err        error
+}
+
+func (e *deliveryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *deliveryError) Unwrap() error {
+	return e.err
+}
+
+// checkResponse classifies the response status code, then drains
+// and closes the body: 2xx is a success, 429 and 5xx are retryable
+// (honoring the 'Retry-After' header), everything else is permanent.
+func checkResponse(resp *http.Response) error {
+	defer func() {
+		io.CopyN(io.Discard, resp.Body, maxDrainBytes)
+		resp.Body.Close()
+	}()
+
+	code := resp.StatusCode
+	if code >= 200 && code < 300 {
+		return nil
+	}
+
+	res := &deliveryError{statusCode: code}
+	switch {
+	case code == http.StatusTooManyRequests:
+		res.reason = reasonThrottled
+		res.retryable = true
+	case code >= 500:
+		res.reason = reasonServerError
+		res.retryable = true
+	case code >= 400:
+		res.reason = reasonClientError
+	default:
+		res.reason = reasonUnexpected
+	}
+
+	if res.retryable {
+		res.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxSnippetBytes))
+	res.err = fmt.Errorf("unexpected response status: %s (body: %q)",
+		resp.Status, strings.TrimSpace(string(snippet)))
+
+	return res
+}
+
+// parseRetryAfter reads the 'Retry-After' header value expressed
+// either as delay seconds or as an HTTP date.
+func parseRetryAfter(val string, now time.Time) time.Duration {
+	val = strings.TrimSpace(val)
+	if len(val) == 0 {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(val); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	when, err := http.ParseTime(val)
+	if err != nil {
+		return 0
+	}
+
+	if d := when.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
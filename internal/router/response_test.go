@@ -0,0 +1,95 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type trackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestCheckResponse(t *testing.T) {
+	table := []struct {
+		code       int
+		retryAfter string
+		reason     string
+		retryable  bool
+		wait       time.Duration
+	}{
+		{code: http.StatusOK},
+		{code: http.StatusAccepted},
+		{code: http.StatusNotFound, reason: reasonClientError},
+		{code: http.StatusBadRequest, reason: reasonClientError},
+		{code: http.StatusTooManyRequests, retryAfter: "7", reason: reasonThrottled, retryable: true, wait: 7 * time.Second},
+		{code: http.StatusInternalServerError, reason: reasonServerError, retryable: true},
+		{code: http.StatusServiceUnavailable, retryAfter: "2", reason: reasonServerError, retryable: true, wait: 2 * time.Second},
+		{code: http.StatusMultipleChoices, reason: reasonUnexpected},
+	}
+
+	for _, tc := range table {
+		body := &trackingBody{Reader: strings.NewReader("some details")}
+		resp := &http.Response{
+			StatusCode: tc.code,
+			Status:     http.StatusText(tc.code),
+			Header:     http.Header{},
+			Body:       body,
+		}
+		if len(tc.retryAfter) > 0 {
+			resp.Header.Set("Retry-After", tc.retryAfter)
+		}
+
+		err := checkResponse(resp)
+		if !body.closed {
+			t.Errorf("%d: expected body to be closed", tc.code)
+		}
+
+		if len(tc.reason) == 0 {
+			if err != nil {
+				t.Errorf("%d: expected no error, got %v", tc.code, err)
+			}
+			continue
+		}
+
+		derr, ok := err.(*deliveryError)
+		if !ok {
+			t.Fatalf("%d: expected deliveryError, got %v", tc.code, err)
+		}
+
+		if derr.reason != tc.reason || derr.retryable != tc.retryable ||
+			derr.retryAfter != tc.wait || derr.statusCode != tc.code {
+			t.Errorf("%d: unexpected classification: %+v", tc.code, derr)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 7, 5, 7, 33, 0, 0, time.UTC)
+
+	table := []struct {
+		val  string
+		want time.Duration
+	}{
+		{"", 0},
+		{"10", 10 * time.Second},
+		{"-1", 0},
+		{"garbage", 0},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second},
+		{now.Add(-30 * time.Second).Format(http.TimeFormat), 0},
+	}
+
+	for _, tc := range table {
+		if got := parseRetryAfter(tc.val, now); got != tc.want {
+			t.Errorf("%q: expected %v, got %v", tc.val, tc.want, got)
+		}
+	}
+}
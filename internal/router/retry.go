@@ -0,0 +1,84 @@
+package router
+
+import (
+	"sync"
+	"time"
+
+	"github.com/krateoplatformops/eventrouter/internal/helpers/queue"
+)
+
+type RetrierOpts struct {
+	// Queue runs the rescheduled jobs
+	Queue queue.Queuer
+}
+
+// NewRetrier creates a retrier pushing the jobs back on the queue once their
+// delay elapsed, so that the workers are not held while waiting.
+func NewRetrier(opts RetrierOpts) *Retrier {
+	return &Retrier{
+		queue:   opts.Queue,
+		pending: map[*time.Timer]*advisor{},
+	}
+}
+
+// Retrier reschedules the failed deliveries; on shutdown the pending ones are
+// cancelled, see advisor.cancel.
+type Retrier struct {
+	queue   queue.Queuer
+	mu      sync.Mutex
+	stopped bool
+	pending map[*time.Timer]*advisor
+}
+
+// Run waits for the stop channel to be closed, then cancels the pending retries.
+func (r *Retrier) Run(stopCh <-chan struct{}) {
+	<-stopCh
+
+	r.mu.Lock()
+	r.stopped = true
+	cancelled := make([]*advisor, 0, len(r.pending))
+	for t, job := range r.pending {
+		if t.Stop() {
+			cancelled = append(cancelled, job)
+		}
+		delete(r.pending, t)
+	}
+	r.mu.Unlock()
+
+	for _, job := range cancelled {
+		job.cancel()
+	}
+}
+
+// after pushes the job back on the queue once the delay elapsed; once the retrier
+// is stopped the job is cancelled instead. It returns false, failing the job at
+// once, when there's no retrier.
+func (r *Retrier) after(delay time.Duration, job *advisor) bool {
+	if r == nil {
+		return false
+	}
+
+	r.mu.Lock()
+	if r.stopped {
+		r.mu.Unlock()
+		job.cancel()
+		return true
+	}
+
+	var t *time.Timer
+	t = time.AfterFunc(delay, func() {
+		r.mu.Lock()
+		delete(r.pending, t)
+		stopped := r.stopped
+		r.mu.Unlock()
+
+		if stopped {
+			job.cancel()
+			return
+		}
+		r.queue.Push(job)
+	})
+	r.pending[t] = job
+	r.mu.Unlock()
+	return true
+}
@@ -2,10 +2,19 @@ package router
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/krateoplatformops/eventrouter/internal/events"
+	"github.com/krateoplatformops/eventrouter/internal/metrics"
+	"github.com/krateoplatformops/eventrouter/internal/objects"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	utilcache "k8s.io/apimachinery/pkg/util/cache"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
@@ -21,52 +30,241 @@ type EventHandler interface {
 // system Events and pushing them to another channel for storage
 type EventRouter struct {
 	handler        EventHandler
-	informer       cache.SharedInformer
 	throttlePeriod time.Duration
+	// dedup holds the recently seen events when watching
+	// both the APIs, that serve the same events
+	dedup *utilcache.LRUExpireCache
+	// active is false while this replica is a standby,
+	// events are cached but not handled
+	active   atomic.Bool
+	activeMu sync.Mutex
+	// claimed holds the events handled since the last activation, so that the
+	// ones both replayed and received meanwhile are handled once
+	claimed *utilcache.LRUExpireCache
+
+	restClient       rest.Interface
+	eventsRESTClient rest.Interface
+	api              events.API
+	resyncInterval   time.Duration
+	// fieldSelector selects server side the watched events
+	fieldSelector fields.Selector
+	// acceptor filters the events by involved object API group and kind
+	acceptor *objects.Acceptor
+	// stamp is true if the events labelled with a composition id are handled
+	stamp bool
+
+	// namespaces are the explicitly watched namespaces, along with
+	// the ones selected by the namespace informer, if any
+	namespaces map[string]bool
+	nsInformer cache.SharedInformer
+	nsSelector labels.Selector
+	stopCh     <-chan struct{}
+	watchersMu sync.RWMutex
+	watchers   map[string]*nsWatcher
 }
 
 type EventRouterOpts struct {
-	RESTClient     rest.Interface
+	// RESTClient is the core/v1 client
+	RESTClient rest.Interface
+	// EventsRESTClient is the events.k8s.io/v1 client
+	EventsRESTClient rest.Interface
+	// EventsAPI selects the watched Events API, core/v1 by default
+	EventsAPI      events.API
 	Handler        EventHandler
 	ResyncInterval time.Duration
 	ThrottlePeriod time.Duration
-	Namespace      string
+	// Namespaces to watch, all when empty and no NamespaceSelector is set
+	Namespaces []string
+	// NamespaceSelector selects the namespaces to watch, in addition to the Namespaces
+	NamespaceSelector labels.Selector
+	// FieldSelector selects server side the watched events, by core/v1 Events keys
+	FieldSelector fields.Selector
+	// Acceptor, if any, filters the events by involved object API group and kind
+	Acceptor *objects.Acceptor
+	// Standby starts the router without handling the events until Activate is called
+	Standby bool
+	// StampEvents handles the events labelled with a composition id, stamped by the
+	// handler; otherwise they're skipped as already labelled
+	StampEvents bool
 }
 
+const (
+	// dedupSize and dedupTTL bound the events remembered to
+	// handle once the ones served by both the APIs
+	dedupSize = 8192
+	dedupTTL  = 10 * time.Minute
+)
+
 // NewEventRouter will create a new event router using the input params
 func NewEventRouter(opts EventRouterOpts) *EventRouter {
-	lw := cache.NewListWatchFromClient(
-		opts.RESTClient,
-		"events",
-		opts.Namespace, // v1.NamespaceAll,
-		fields.Everything(),
-	)
+	res := &EventRouter{
+		handler:          opts.Handler,
+		throttlePeriod:   opts.ThrottlePeriod,
+		restClient:       opts.RESTClient,
+		eventsRESTClient: opts.EventsRESTClient,
+		api:              opts.EventsAPI,
+		resyncInterval:   opts.ResyncInterval,
+		fieldSelector:    opts.FieldSelector,
+		acceptor:         opts.Acceptor,
+		stamp:            opts.StampEvents,
+		namespaces:       map[string]bool{},
+		watchers:         map[string]*nsWatcher{},
+	}
+
+	if res.fieldSelector == nil {
+		res.fieldSelector = fields.Everything()
+	}
+
+	if len(res.api) == 0 {
+		res.api = events.APICore
+	}
+
+	if res.api == events.APIBoth {
+		res.dedup = utilcache.NewLRUExpireCache(dedupSize)
+	}
+
+	for _, ns := range opts.Namespaces {
+		res.namespaces[ns] = true
+	}
+
+	if sel := opts.NamespaceSelector; sel != nil && !sel.Empty() {
+		res.nsSelector = sel
+		res.nsInformer = newNamespaceInformer(opts.RESTClient, sel, opts.ResyncInterval)
+	} else if len(res.namespaces) == 0 {
+		res.namespaces[metav1.NamespaceAll] = true
+	}
+
+	for ns := range res.namespaces {
+		res.watchers[ns] = res.newWatcher(ns)
+	}
+
+	res.active.Store(!opts.Standby)
+	metrics.SetLeader(!opts.Standby)
+
+	return res
+}
+
+// Activate starts handling the events, the cached ones that
+// happened after 'since' are replayed so that the events
+// received while this replica was a standby are not lost.
+func (er *EventRouter) Activate(since time.Time) {
+	er.activeMu.Lock()
+	if er.active.Load() {
+		er.activeMu.Unlock()
+		return
+	}
+	er.claimed = utilcache.NewLRUExpireCache(dedupSize)
+	er.active.Store(true)
+	er.activeMu.Unlock()
+
+	metrics.SetLeader(true)
+
+	replayed := 0
+	for _, inf := range er.informers() {
+		for _, obj := range inf.GetStore().List() {
+			event, ok := events.Normalize(obj)
+			if !ok || eventTime(event).Before(since) {
+				continue
+			}
+
+			er.onEvent(event)
+			replayed++
+		}
+	}
+
+	klog.InfoS("EventRouter activated", "since", since, "replayed", replayed)
+}
+
+// Deactivate stops handling the events, they are still cached.
+func (er *EventRouter) Deactivate() {
+	er.activeMu.Lock()
+	defer er.activeMu.Unlock()
+
+	if !er.active.Swap(false) {
+		return
+	}
+	er.claimed = nil
+	metrics.SetLeader(false)
+
+	klog.InfoS("EventRouter deactivated")
+}
+
+// admit reports whether the event must be handled: not while a standby, nor if
+// already handled since the activation (i.e. both replayed and received).
+func (er *EventRouter) admit(event *corev1.Event) bool {
+	er.activeMu.Lock()
+	defer er.activeMu.Unlock()
+
+	if !er.active.Load() {
+		metrics.EventSkipped(event.Type, event.Reason, metrics.SkipStandby)
+		return false
+	}
+
+	if er.claimed == nil {
+		return true
+	}
+
+	key := events.Key(event)
+	if _, ok := er.claimed.Get(key); ok {
+		metrics.EventSkipped(event.Type, event.Reason, metrics.SkipDuplicate)
+		return false
+	}
+	er.claimed.Add(key, struct{}{}, dedupTTL)
+	return true
+}
+
+// SetFieldSelector changes the field selector of the watched events, restarting the
+// watchers; the events already received, or happened before the change, are not
+// handled when relisted.
+func (er *EventRouter) SetFieldSelector(sel fields.Selector) {
+	er.watchersMu.Lock()
+	defer er.watchersMu.Unlock()
 
-	si := cache.NewSharedInformer(lw, &corev1.Event{}, opts.ResyncInterval)
+	if sel.String() == er.fieldSelector.String() {
+		return
+	}
+	er.fieldSelector = sel
 
-	return &EventRouter{
-		informer:       si,
-		handler:        opts.Handler,
-		throttlePeriod: opts.ThrottlePeriod,
+	now := time.Now()
+	for ns, old := range er.watchers {
+		w := er.newWatcher(ns)
+		w.known = old.keys()
+		w.since = now
+		old.stop()
+		if er.stopCh != nil {
+			w.start(er.stopCh)
+		}
+		er.watchers[ns] = w
 	}
+
+	klog.InfoS("events field selector changed, watchers restarted", "fieldSelector", sel.String())
 }
 
 // Run starts the EventRouter/Controller.
 func (er *EventRouter) Run(stopCh <-chan struct{}) {
-	er.informer.AddEventHandler(
-		cache.ResourceEventHandlerFuncs{
-			AddFunc:    er.OnAdd,
-			UpdateFunc: er.OnUpdate,
-			DeleteFunc: er.OnDelete,
-		},
-	)
-
 	defer utilruntime.HandleCrash()
 
-	er.informer.Run(stopCh)
+	er.watchersMu.Lock()
+	er.stopCh = stopCh
+	for _, w := range er.watchers {
+		w.start(stopCh)
+	}
+	er.watchersMu.Unlock()
+
+	if er.nsInformer != nil {
+		er.nsInformer.AddEventHandler(
+			cache.ResourceEventHandlerDetailedFuncs{
+				AddFunc:    er.onNamespace,
+				UpdateFunc: func(_, obj interface{}) { er.onNamespace(obj, false) },
+				DeleteFunc: er.onNamespaceDelete,
+			},
+		)
+
+		go er.nsInformer.Run(stopCh)
+	}
 
 	// here is where we kick the caches into gear
-	if !cache.WaitForCacheSync(stopCh, er.informer.HasSynced) {
+	if !cache.WaitForCacheSync(stopCh, er.HasSynced) {
 		utilruntime.HandleError(fmt.Errorf("timed out waiting for caches to sync"))
 		return
 	}
@@ -74,16 +272,59 @@ func (er *EventRouter) Run(stopCh <-chan struct{}) {
 	<-stopCh
 }
 
+// HasSynced returns true once the initial list of events has been received.
+func (er *EventRouter) HasSynced() bool {
+	if er.nsInformer != nil && !er.nsInformer.HasSynced() {
+		return false
+	}
+
+	for _, inf := range er.informers() {
+		if !inf.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
 // OnAdd is called when an event is created, or during the initial list
 func (er *EventRouter) OnAdd(obj interface{}) {
-	event := obj.(*corev1.Event)
-	er.onEvent(event)
+	er.handle(obj)
 }
 
-// OnUpdate is called any time there is an update to an existing event
+// OnUpdate is called any time there is an update to an existing event,
+// and on every resync with the same event as old and new object
 func (er *EventRouter) OnUpdate(objOld interface{}, objNew interface{}) {
-	event := objNew.(*corev1.Event)
-	er.onEvent(event)
+	oldMeta, err := meta.Accessor(objOld)
+	if err != nil {
+		return
+	}
+	newMeta, err := meta.Accessor(objNew)
+	if err != nil {
+		return
+	}
+
+	if oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+		if event, ok := events.Normalize(objNew); ok {
+			metrics.EventSkipped(event.Type, event.Reason, metrics.SkipResync)
+		}
+		klog.V(6).InfoS("Event unchanged (resync)",
+			"name", newMeta.GetName(),
+			"namespace", newMeta.GetNamespace(),
+			"resourceVersion", newMeta.GetResourceVersion())
+		return
+	}
+
+	if stampedOnly(objOld, objNew) {
+		if event, ok := events.Normalize(objNew); ok {
+			metrics.EventSkipped(event.Type, event.Reason, metrics.SkipStamped)
+		}
+		klog.V(6).InfoS("Event stamped with the composition id",
+			"name", newMeta.GetName(),
+			"namespace", newMeta.GetNamespace())
+		return
+	}
+
+	er.handle(objNew)
 }
 
 // OnDelete should only occur when the system garbage collects events via TTL expiration
@@ -92,10 +333,33 @@ func (er *EventRouter) OnDelete(obj interface{}) {
 		return
 	}
 
-	e := obj.(*corev1.Event)
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
 	// NOTE: This should *only* happen on TTL expiration there
 	// is no reason to push this to a collector
-	klog.V(6).Infof("Event deleted from the system: %v", e)
+	klog.V(6).Infof("Event deleted from the system: %v", obj)
+}
+
+// handle normalizes the event and, watching both the APIs,
+// drops the one already received from the other API
+func (er *EventRouter) handle(obj interface{}) {
+	event, ok := events.Normalize(obj)
+	if !ok {
+		return
+	}
+
+	if er.dedup != nil {
+		key := events.Key(event)
+		if _, ok := er.dedup.Get(key); ok {
+			metrics.EventSkipped(event.Type, event.Reason, metrics.SkipDuplicate)
+			return
+		}
+		er.dedup.Add(key, struct{}{}, dedupTTL)
+	}
+
+	er.onEvent(event)
 }
 
 func (er *EventRouter) onEvent(event *corev1.Event) {
@@ -105,7 +369,20 @@ func (er *EventRouter) onEvent(event *corev1.Event) {
 		"reason", event.Reason,
 		"involvedObject", event.InvolvedObject.Name)
 
-	if hasCompositionId(event) {
+	metrics.EventReceived(event.Type, event.Reason)
+
+	if !er.admit(event) {
+		return
+	}
+
+	// the involved objects not accepted need no composition id resolution
+	if !er.acceptor.Accept(&event.InvolvedObject) {
+		metrics.EventSkipped(event.Type, event.Reason, metrics.SkipNotAccepted)
+		return
+	}
+
+	if !er.stamp && hasCompositionId(event) {
+		metrics.EventSkipped(event.Type, event.Reason, metrics.SkipCompositionIDPresent)
 		klog.V(4).InfoS("CompositionID already present",
 			"msg", event.Message,
 			"namespace", event.Namespace,
@@ -115,13 +392,10 @@ func (er *EventRouter) onEvent(event *corev1.Event) {
 	}
 
 	// It's probably an old event we are catching, it's not the best way but anyways
-	if er.throttlePeriod > 0 && time.Since(event.LastTimestamp.Time) > er.throttlePeriod {
+	if er.throttlePeriod > 0 && time.Since(eventTime(event)) > er.throttlePeriod {
+		metrics.EventSkipped(event.Type, event.Reason, metrics.SkipThrottled)
 		return
 	}
 
-	// if !objects.Accept(&event.InvolvedObject) {
-	// 	return
-	// }
-
-	er.handler.Handle(*event.DeepCopy())
+	er.handler.Handle(*event)
 }
@@ -0,0 +1,200 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/eventrouter/internal/events"
+	"github.com/krateoplatformops/eventrouter/internal/objects"
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+type recordingHandler struct {
+	names []string
+}
+
+func (h *recordingHandler) Handle(e corev1.Event) {
+	h.names = append(h.names, e.Name)
+}
+
+func TestEventRouterStandby(t *testing.T) {
+	now := time.Now()
+
+	h := &recordingHandler{}
+	er := NewEventRouter(EventRouterOpts{
+		Handler: h,
+		Standby: true,
+	})
+
+	events := []*corev1.Event{
+		{
+			ObjectMeta:    metav1.ObjectMeta{Name: "old", Namespace: "default"},
+			LastTimestamp: metav1.NewTime(now.Add(-time.Hour)),
+		},
+		{
+			ObjectMeta:    metav1.ObjectMeta{Name: "recent", Namespace: "default"},
+			LastTimestamp: metav1.NewTime(now.Add(-time.Second)),
+		},
+	}
+	for _, el := range events {
+		if err := er.informers()[0].GetStore().Add(el); err != nil {
+			t.Fatal(err)
+		}
+		er.OnAdd(el)
+	}
+
+	if len(h.names) != 0 {
+		t.Fatalf("expected no events handled by a standby, got %v", h.names)
+	}
+
+	er.Activate(now.Add(-time.Minute))
+	if len(h.names) != 1 || h.names[0] != "recent" {
+		t.Fatalf("expected only the recent event replayed, got %v", h.names)
+	}
+
+	er.Deactivate()
+	er.OnAdd(events[1])
+	if len(h.names) != 1 {
+		t.Fatalf("expected no events handled after deactivation, got %v", h.names)
+	}
+}
+
+func TestEventRouterActivateOnce(t *testing.T) {
+	h := &recordingHandler{}
+	er := NewEventRouter(EventRouterOpts{
+		Handler: h,
+		Standby: true,
+	})
+
+	evt := &corev1.Event{
+		ObjectMeta:    metav1.ObjectMeta{Name: "recent", Namespace: "default", UID: "1", ResourceVersion: "1"},
+		LastTimestamp: metav1.NewTime(time.Now()),
+	}
+	if err := er.informers()[0].GetStore().Add(evt); err != nil {
+		t.Fatal(err)
+	}
+
+	er.Activate(time.Time{})
+	// the notification of the replayed event received late
+	er.OnAdd(evt)
+
+	if len(h.names) != 1 {
+		t.Fatalf("expected the event handled once, got %v", h.names)
+	}
+
+	// a new revision is handled
+	updated := evt.DeepCopy()
+	updated.ResourceVersion = "2"
+	er.OnUpdate(evt, updated)
+
+	if len(h.names) != 2 {
+		t.Fatalf("expected the updated event handled, got %v", h.names)
+	}
+}
+
+func TestEventRouterResync(t *testing.T) {
+	h := &recordingHandler{}
+	er := NewEventRouter(EventRouterOpts{Handler: h})
+
+	old := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", ResourceVersion: "1"},
+	}
+	er.OnUpdate(old, old.DeepCopy())
+	if len(h.names) != 0 {
+		t.Fatalf("expected resync to be suppressed, got %v", h.names)
+	}
+
+	upd := old.DeepCopy()
+	upd.ResourceVersion = "2"
+	er.OnUpdate(old, upd)
+	if len(h.names) != 1 {
+		t.Fatalf("expected update to be handled, got %v", h.names)
+	}
+}
+
+func TestEventRouterBothAPIs(t *testing.T) {
+	h := &recordingHandler{}
+	er := NewEventRouter(EventRouterOpts{
+		Handler:   h,
+		EventsAPI: events.APIBoth,
+	})
+
+	meta := metav1.ObjectMeta{Name: "test", UID: "abc", ResourceVersion: "1"}
+	er.OnAdd(&corev1.Event{ObjectMeta: meta})
+	er.OnAdd(&eventsv1.Event{ObjectMeta: meta})
+
+	if len(h.names) != 1 {
+		t.Fatalf("expected the event served by both APIs handled once, got %v", h.names)
+	}
+
+	meta.ResourceVersion = "2"
+	er.OnUpdate(&eventsv1.Event{}, &eventsv1.Event{ObjectMeta: meta})
+	if len(h.names) != 2 {
+		t.Fatalf("expected the updated event handled, got %v", h.names)
+	}
+}
+
+func TestEventRouterSetFieldSelector(t *testing.T) {
+	h := &recordingHandler{}
+	er := NewEventRouter(EventRouterOpts{Handler: h})
+
+	old := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "old", Namespace: "default", UID: "1", ResourceVersion: "10"},
+	}
+	if err := er.informers()[0].GetStore().Add(old); err != nil {
+		t.Fatal(err)
+	}
+
+	er.SetFieldSelector(fields.OneTermEqualSelector("type", corev1.EventTypeWarning))
+	later := metav1.NewTime(time.Now().Add(time.Second))
+
+	w := er.watchers[""]
+	if w.relisted(&corev1.Event{ObjectMeta: metav1.ObjectMeta{UID: "2", ResourceVersion: "11"}, LastTimestamp: later}) {
+		t.Fatal("expected a new event not relisted")
+	}
+	if !w.relisted(old) {
+		t.Fatal("expected the cached event relisted")
+	}
+
+	// updated since cached
+	if w.relisted(&corev1.Event{ObjectMeta: metav1.ObjectMeta{UID: "1", ResourceVersion: "12"}, LastTimestamp: later}) {
+		t.Fatal("expected an updated event not relisted")
+	}
+
+	// excluded by the previous selector, happened before the change
+	historic := &corev1.Event{
+		ObjectMeta:    metav1.ObjectMeta{UID: "3", ResourceVersion: "5"},
+		LastTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+	}
+	if !w.relisted(historic) {
+		t.Fatal("expected an event happened before the change not handled")
+	}
+}
+
+func TestEventRouterAcceptor(t *testing.T) {
+	acceptor, err := objects.NewAcceptor(objects.AcceptorOpts{
+		Rules: objects.AcceptRules{Allow: []string{"*.krateo.io"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &recordingHandler{}
+	er := NewEventRouter(EventRouterOpts{Handler: h, Acceptor: acceptor})
+
+	er.OnAdd(&corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "pod", Namespace: "default", UID: "1"},
+		InvolvedObject: corev1.ObjectReference{APIVersion: "v1", Kind: "Pod"},
+	})
+	er.OnAdd(&corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "composition", Namespace: "default", UID: "2"},
+		InvolvedObject: corev1.ObjectReference{APIVersion: "composition.krateo.io/v1alpha1", Kind: "FireworksApp"},
+	})
+
+	if len(h.names) != 1 || h.names[0] != "composition" {
+		t.Fatalf("expected only the accepted event handled, got %v", h.names)
+	}
+}
@@ -0,0 +1,86 @@
+package router
+
+import (
+	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+// fieldSelectorOf derives the field selector of the events accepted by at least one of
+// the registrations; field selectors cannot express alternatives, so only the filter
+// values shared by all the registrations are selected server side, and the filters are
+// still evaluated on every received event.
+func fieldSelectorOf(all []*registration) fields.Selector {
+	if len(all) == 0 {
+		return fields.Everything()
+	}
+
+	sels := []fields.Selector{}
+
+	common := []struct {
+		field string
+		list  func(f *v1alpha1.RegistrationFilter) []string
+	}{
+		{field: "type", list: func(f *v1alpha1.RegistrationFilter) []string { return f.Types }},
+		{field: "reason", list: func(f *v1alpha1.RegistrationFilter) []string { return f.Reasons }},
+		{field: "involvedObject.kind", list: func(f *v1alpha1.RegistrationFilter) []string { return f.Kinds }},
+		{field: "metadata.namespace", list: func(f *v1alpha1.RegistrationFilter) []string { return f.Namespaces }},
+	}
+	for _, el := range common {
+		if val, ok := commonValue(all, el.list); ok {
+			sels = append(sels, fields.OneTermEqualSelector(el.field, val))
+		}
+	}
+
+	for _, val := range commonExcludedReasons(all) {
+		sels = append(sels, fields.OneTermNotEqualSelector("reason", val))
+	}
+
+	return fields.AndSelectors(sels...)
+}
+
+// commonValue returns the only value accepted by all the registrations, if any.
+func commonValue(all []*registration, list func(f *v1alpha1.RegistrationFilter) []string) (string, bool) {
+	res := ""
+	for _, el := range all {
+		f := el.spec.Filter
+		if f == nil || len(list(f)) != 1 {
+			return "", false
+		}
+
+		val := list(f)[0]
+		if len(res) > 0 && val != res {
+			return "", false
+		}
+		res = val
+	}
+	return res, len(res) > 0
+}
+
+// commonExcludedReasons returns the reasons rejected by all the registrations.
+func commonExcludedReasons(all []*registration) []string {
+	res := []string{}
+	for i, el := range all {
+		f := el.spec.Filter
+		if f == nil {
+			return nil
+		}
+
+		if i == 0 {
+			res = append(res, f.ExcludedReasons...)
+			continue
+		}
+
+		res = intersect(res, f.ExcludedReasons)
+	}
+	return res
+}
+
+func intersect(a, b []string) []string {
+	res := []string{}
+	for _, x := range a {
+		if len(b) > 0 && matchAny(b, x) {
+			res = append(res, x)
+		}
+	}
+	return res
+}
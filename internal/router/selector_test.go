@@ -0,0 +1,80 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+)
+
+func TestFieldSelectorOf(t *testing.T) {
+	reg := func(f *v1alpha1.RegistrationFilter) *registration {
+		return &registration{spec: v1alpha1.RegistrationSpec{Filter: f}}
+	}
+
+	table := []struct {
+		name string
+		all  []*registration
+		want string
+	}{
+		{
+			name: "no registrations",
+			want: "",
+		},
+		{
+			name: "unfiltered registration",
+			all: []*registration{
+				reg(&v1alpha1.RegistrationFilter{Types: []string{"Warning"}}),
+				reg(nil),
+			},
+			want: "",
+		},
+		{
+			name: "shared values",
+			all: []*registration{
+				reg(&v1alpha1.RegistrationFilter{
+					Types:      []string{"Warning"},
+					Kinds:      []string{"NodeGroup"},
+					Namespaces: []string{"demo-system"},
+				}),
+				reg(&v1alpha1.RegistrationFilter{
+					Types:      []string{"Warning"},
+					Kinds:      []string{"Cluster"},
+					Namespaces: []string{"demo-system"},
+				}),
+			},
+			want: "type=Warning,metadata.namespace=demo-system",
+		},
+		{
+			name: "many values",
+			all: []*registration{
+				reg(&v1alpha1.RegistrationFilter{Types: []string{"Warning", "Normal"}}),
+			},
+			want: "",
+		},
+		{
+			name: "shared excluded reasons",
+			all: []*registration{
+				reg(&v1alpha1.RegistrationFilter{ExcludedReasons: []string{"Pulled", "Scheduled"}}),
+				reg(&v1alpha1.RegistrationFilter{ExcludedReasons: []string{"Scheduled", "Created"}}),
+			},
+			want: "reason!=Scheduled",
+		},
+		{
+			name: "excluded reasons not shared",
+			all: []*registration{
+				reg(&v1alpha1.RegistrationFilter{ExcludedReasons: []string{"Pulled"}}),
+				reg(&v1alpha1.RegistrationFilter{Types: []string{"Warning"}}),
+			},
+			want: "",
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			got := fieldSelectorOf(tc.all).String()
+			if got != tc.want {
+				t.Fatalf("got: %s, expected: %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/krateoplatformops/eventrouter/internal/events"
+	"github.com/krateoplatformops/eventrouter/internal/objects"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+)
+
+// stampEvent merge-patches the composition id label, along with the composition source
+// and correlations annotations, onto the event; so that the events can be selected by
+// composition and their next updates skip the composition id resolution. A composition id
+// not valid as label value (i.e. from an annotation) is not stamped.
+func stampEvent(resolver *objects.ObjectResolver, evt *corev1.Event) {
+	compositionId := evt.GetLabels()[keyCompositionID]
+	if errs := validation.IsValidLabelValue(compositionId); len(errs) > 0 {
+		klog.V(4).InfoS("event not stamped, the composition id is not a valid label value",
+			"name", evt.Name, "namespace", evt.Namespace, "compositionId", compositionId,
+			"err", strings.Join(errs, "; "))
+		return
+	}
+
+	annotations := map[string]string{}
+	for _, k := range []string{keyCompositionSource, keyCorrelations} {
+		if val, ok := evt.GetAnnotations()[k]; ok {
+			annotations[k] = val
+		}
+	}
+
+	// the resource version fails the patch with a conflict if the event has been updated
+	dat, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"resourceVersion": evt.ResourceVersion,
+			"labels": map[string]string{
+				keyCompositionID: compositionId,
+			},
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		klog.ErrorS(err, "unable to encode event patch", "name", evt.Name, "namespace", evt.Namespace)
+		return
+	}
+
+	err = resolver.Patch(context.Background(), objects.PatchOpts{
+		PatchData: dat,
+		GVK:       corev1.SchemeGroupVersion.WithKind("Event"),
+		Name:      evt.Name,
+		Namespace: evt.Namespace,
+	})
+	switch {
+	case err == nil:
+		klog.V(4).InfoS("event stamped with the composition id",
+			"name", evt.Name, "namespace", evt.Namespace)
+	case apierrors.IsConflict(err), apierrors.IsNotFound(err):
+		// the event has been updated, its next revision is handled and
+		// stamped in turn, or deleted (i.e. expired) meanwhile
+		klog.V(4).InfoS("event not stamped with the composition id",
+			"name", evt.Name, "namespace", evt.Namespace, "err", err.Error())
+	default:
+		klog.ErrorS(err, "unable to stamp the composition id on the event",
+			"name", evt.Name, "namespace", evt.Namespace)
+	}
+}
+
+// stampedOnly reports whether the event update only stamped the composition id on it.
+func stampedOnly(objOld, objNew interface{}) bool {
+	oldEvt, ok := events.Normalize(objOld)
+	if !ok || hasCompositionId(oldEvt) {
+		return false
+	}
+
+	newEvt, ok := events.Normalize(objNew)
+	if !ok || !hasCompositionId(newEvt) {
+		return false
+	}
+
+	return oldEvt.Count == newEvt.Count &&
+		oldEvt.LastTimestamp.Equal(&newEvt.LastTimestamp) &&
+		oldEvt.Type == newEvt.Type &&
+		oldEvt.Reason == newEvt.Reason &&
+		oldEvt.Message == newEvt.Message
+}
@@ -0,0 +1,111 @@
+package router
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStampedOnly(t *testing.T) {
+	ts := metav1.NewTime(time.Date(2024, 6, 10, 8, 0, 0, 0, time.UTC))
+
+	old := &corev1.Event{
+		ObjectMeta:    metav1.ObjectMeta{Name: "test", Namespace: "default", ResourceVersion: "1"},
+		Type:          corev1.EventTypeWarning,
+		Reason:        "BackOff",
+		Message:       "back-off restarting failed container",
+		Count:         3,
+		LastTimestamp: ts,
+	}
+
+	stamped := old.DeepCopy()
+	stamped.ResourceVersion = "2"
+	stamped.Labels = map[string]string{keyCompositionID: "1234"}
+
+	repeated := stamped.DeepCopy()
+	repeated.ResourceVersion = "3"
+	repeated.Count = 4
+	repeated.LastTimestamp = metav1.NewTime(ts.Add(time.Minute))
+
+	table := []struct {
+		name     string
+		old, new *corev1.Event
+		want     bool
+	}{
+		{name: "stamped", old: old, new: stamped, want: true},
+		{name: "repeated", old: stamped, new: repeated, want: false},
+		{name: "stamped and repeated", old: old, new: repeated, want: false},
+		{name: "unstamped", old: old, new: old, want: false},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := stampedOnly(tc.old, tc.new); got != tc.want {
+				t.Fatalf("got: %v, expected: %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStampEventInvalidLabelValue(t *testing.T) {
+	table := []string{
+		strings.Repeat("a", 64),
+		"my release",
+		"-web",
+	}
+
+	for _, cid := range table {
+		t.Run(cid, func(t *testing.T) {
+			evt := &corev1.Event{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+					Labels:    map[string]string{keyCompositionID: cid},
+				},
+			}
+
+			// skipped before patching, the nil resolver would panic otherwise
+			stampEvent(nil, evt)
+		})
+	}
+}
+
+func TestEventRouterStamped(t *testing.T) {
+	old := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", ResourceVersion: "1"},
+		Count:      1,
+	}
+	stamped := old.DeepCopy()
+	stamped.ResourceVersion = "2"
+	stamped.Labels = map[string]string{keyCompositionID: "1234"}
+
+	repeated := stamped.DeepCopy()
+	repeated.ResourceVersion = "3"
+	repeated.Count = 2
+
+	table := []struct {
+		stamp bool
+		want  int
+	}{
+		// the event and its labelled update, not the stamp
+		{stamp: true, want: 2},
+		// the labelled events are skipped
+		{stamp: false, want: 1},
+	}
+
+	for _, tc := range table {
+		h := &recordingHandler{}
+		er := NewEventRouter(EventRouterOpts{Handler: h, StampEvents: tc.stamp})
+
+		er.OnAdd(old)
+		er.OnUpdate(old, stamped)
+		er.OnUpdate(stamped, repeated)
+
+		if len(h.names) != tc.want {
+			t.Errorf("stamp %v: expected %d events handled, got %v", tc.stamp, tc.want, h.names)
+		}
+	}
+}
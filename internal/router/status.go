@@ -0,0 +1,215 @@
+package router
+
+import (
+	"context"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+	"github.com/krateoplatformops/eventrouter/internal/objects"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// maxStatusErrorLen bounds the error reported on the registration status
+	maxStatusErrorLen = 512
+)
+
+type StatusRecorderOpts struct {
+	RESTConfig    *rest.Config
+	Registrations *RegistrationStore
+	// Interval is the minimum time between two status updates
+	// of the same registration.
+	Interval time.Duration
+}
+
+// NewStatusRecorder creates a recorder that collects the delivery
+// outcomes and periodically reports them on the Registrations status.
+func NewStatusRecorder(opts StatusRecorderOpts) (*StatusRecorder, error) {
+	objectResolver, err := objects.NewObjectResolver(opts.RESTConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	return &StatusRecorder{
+		objectResolver: objectResolver,
+		registrations:  opts.Registrations,
+		interval:       interval,
+		pending:        map[string]*deliveryStats{},
+	}, nil
+}
+
+// StatusRecorder aggregates the delivery outcomes per registration.
+type StatusRecorder struct {
+	objectResolver *objects.ObjectResolver
+	registrations  *RegistrationStore
+	interval       time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*deliveryStats
+}
+
+// deliveryStats are the outcomes collected since the last status update.
+type deliveryStats struct {
+	delivered   int64
+	failed      int64
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastError   string
+	// reset is true if a delivery succeeded, in that case the
+	// consecutive failures are the ones counted after it
+	reset    bool
+	failures int64
+}
+
+// Run flushes the collected outcomes every interval until the stop channel is closed.
+func (r *StatusRecorder) Run(stopCh <-chan struct{}) {
+	wait.Until(r.flush, r.interval, stopCh)
+	r.flush()
+}
+
+// record collects the outcome of a delivery, a nil error means success.
+func (r *StatusRecorder) record(name string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st, ok := r.pending[name]
+	if !ok {
+		st = &deliveryStats{}
+		r.pending[name] = st
+	}
+
+	if err == nil {
+		st.delivered++
+		st.lastSuccess = time.Now()
+		st.reset = true
+		st.failures = 0
+		return
+	}
+
+	st.failed++
+	st.failures++
+	st.lastFailure = time.Now()
+	st.lastError = err.Error()
+}
+
+func (r *StatusRecorder) flush() {
+	r.mu.Lock()
+	all := r.pending
+	r.pending = map[string]*deliveryStats{}
+	r.mu.Unlock()
+
+	for name, st := range all {
+		reg, ok := r.registrations.get(name)
+		if !ok {
+			continue
+		}
+
+		// the resource version guards against overwriting a newer status,
+		// on failure the outcomes are merged again on the next flush
+		status := st.apply(reg.Status, reg.Generation)
+		err := patchStatus(context.Background(), r.objectResolver, name, reg.ResourceVersion, status)
+		if err == nil {
+			continue
+		}
+		r.restore(name, st)
+
+		if apierrors.IsConflict(err) {
+			klog.V(4).InfoS("registration changed, status update postponed", "registration", name)
+			continue
+		}
+		klog.ErrorS(err, "unable to update registration status", "registration", name)
+	}
+}
+
+// restore puts back the outcomes not reported, before the ones collected meanwhile.
+func (r *StatusRecorder) restore(name string, st *deliveryStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if next, ok := r.pending[name]; ok {
+		st.merge(next)
+	}
+	r.pending[name] = st
+}
+
+// merge adds the outcomes collected after the receiver ones.
+func (st *deliveryStats) merge(next *deliveryStats) {
+	st.delivered += next.delivered
+	st.failed += next.failed
+
+	if next.reset {
+		st.reset = true
+		st.failures = next.failures
+	} else {
+		st.failures += next.failures
+	}
+
+	if next.lastSuccess.After(st.lastSuccess) {
+		st.lastSuccess = next.lastSuccess
+	}
+	if next.lastFailure.After(st.lastFailure) {
+		st.lastFailure = next.lastFailure
+		st.lastError = next.lastError
+	}
+}
+
+// apply merges the collected outcomes into the registration status.
+func (st *deliveryStats) apply(status v1alpha1.RegistrationStatus, generation int64) v1alpha1.RegistrationStatus {
+	res := *status.DeepCopy()
+
+	res.Delivered += st.delivered
+	res.Failed += st.failed
+
+	if st.reset {
+		res.ConsecutiveFailures = st.failures
+	} else {
+		res.ConsecutiveFailures += st.failures
+	}
+
+	if !st.lastSuccess.IsZero() {
+		res.LastDeliveryTime = &metav1.Time{Time: st.lastSuccess}
+	}
+
+	if !st.lastFailure.IsZero() {
+		res.LastFailureTime = &metav1.Time{Time: st.lastFailure}
+		res.LastError = truncate(st.lastError, maxStatusErrorLen)
+	}
+
+	cond := metav1.Condition{
+		Type:               v1alpha1.TypeDegraded,
+		Status:             metav1.ConditionFalse,
+		Reason:             v1alpha1.ReasonDeliverySucceeded,
+		ObservedGeneration: generation,
+	}
+	if res.ConsecutiveFailures > 0 {
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = v1alpha1.ReasonDeliveryFailed
+		cond.Message = res.LastError
+	}
+	meta.SetStatusCondition(&res.Conditions, cond)
+
+	return res
+}
+
+// truncate cuts the string to at most n bytes, on a rune boundary.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,143 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+func TestDeliveryStatsApply(t *testing.T) {
+	r := &StatusRecorder{pending: map[string]*deliveryStats{}}
+
+	r.record("foo", errors.New("boom"))
+	r.record("foo", errors.New("boom again"))
+
+	status := r.pending["foo"].apply(v1alpha1.RegistrationStatus{
+		ConsecutiveFailures: 1,
+		Delivered:           10,
+		Failed:              1,
+	}, 1)
+
+	if status.Delivered != 10 || status.Failed != 3 || status.ConsecutiveFailures != 3 {
+		t.Errorf("unexpected counters: %+v", status)
+	}
+
+	if status.LastError != "boom again" || status.LastFailureTime == nil {
+		t.Errorf("unexpected last failure: %+v", status)
+	}
+
+	if !meta.IsStatusConditionTrue(status.Conditions, v1alpha1.TypeDegraded) {
+		t.Errorf("expected degraded condition: %+v", status.Conditions)
+	}
+
+	// the pending outcomes are reset on every flush
+	r.pending = map[string]*deliveryStats{}
+	r.record("foo", nil)
+	r.record("foo", errors.New("boom"))
+
+	status = r.pending["foo"].apply(status, 1)
+	if status.Delivered != 11 || status.Failed != 4 || status.ConsecutiveFailures != 1 {
+		t.Errorf("unexpected counters: %+v", status)
+	}
+
+	r.pending = map[string]*deliveryStats{}
+	r.record("foo", nil)
+
+	status = r.pending["foo"].apply(status, 1)
+	if status.Delivered != 12 || status.ConsecutiveFailures != 0 || status.LastDeliveryTime == nil {
+		t.Errorf("unexpected counters: %+v", status)
+	}
+
+	if !meta.IsStatusConditionFalse(status.Conditions, v1alpha1.TypeDegraded) {
+		t.Errorf("expected not degraded condition: %+v", status.Conditions)
+	}
+}
+
+func TestStatusRecorderRestore(t *testing.T) {
+	r := &StatusRecorder{pending: map[string]*deliveryStats{}}
+
+	r.record("foo", errors.New("boom"))
+	r.record("foo", errors.New("boom again"))
+
+	// a failed flush puts back the outcomes
+	st := r.pending["foo"]
+	r.pending = map[string]*deliveryStats{}
+
+	// collected meanwhile
+	r.record("foo", errors.New("later"))
+	r.restore("foo", st)
+
+	status := r.pending["foo"].apply(v1alpha1.RegistrationStatus{Delivered: 1}, 1)
+	if status.Delivered != 1 || status.Failed != 3 || status.ConsecutiveFailures != 3 || status.LastError != "later" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	st = r.pending["foo"]
+	r.pending = map[string]*deliveryStats{}
+	r.record("foo", nil)
+	r.restore("foo", st)
+
+	status = r.pending["foo"].apply(status, 1)
+	if status.Delivered != 2 || status.Failed != 6 || status.ConsecutiveFailures != 0 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
+
+func TestStatusPatchRecovered(t *testing.T) {
+	r := &StatusRecorder{pending: map[string]*deliveryStats{}}
+	r.record("foo", nil)
+
+	status := r.pending["foo"].apply(v1alpha1.RegistrationStatus{
+		ConsecutiveFailures: 5,
+		Failed:              5,
+	}, 1)
+
+	dat, err := statusPatch("42", status)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var patch struct {
+		Metadata map[string]any `json:"metadata"`
+		Status   map[string]any `json:"status"`
+	}
+	if err := json.Unmarshal(dat, &patch); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := patch.Metadata["resourceVersion"]; got != "42" {
+		t.Errorf("expected resourceVersion 42, got: %v", got)
+	}
+
+	// the zeroed counter must be sent explicitly, a merge patch would keep the old value
+	got, ok := patch.Status["consecutiveFailures"]
+	if !ok || got != float64(0) {
+		t.Errorf("expected consecutiveFailures 0 in the patch, got: %s", dat)
+	}
+	if patch.Status["delivered"] != float64(1) || patch.Status["failed"] != float64(5) {
+		t.Errorf("unexpected counters in the patch: %s", dat)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	table := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"boom", 10, "boom"},
+		{"boom", 2, "bo"},
+		{"àèì", 3, "à"},
+		{"àèì", 4, "àè"},
+		{"日本", 2, ""},
+	}
+
+	for _, tc := range table {
+		if got := truncate(tc.in, tc.n); got != tc.want {
+			t.Errorf("truncate(%q, %d): expected %q, got %q", tc.in, tc.n, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+	"github.com/krateoplatformops/eventrouter/internal/objects"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+type RegistrationStoreOpts struct {
+	RESTConfig     *rest.Config
+	ResyncInterval time.Duration
+}
+
+// NewRegistrationStore creates a store of all the Registrations
+// kept up to date by an informer.
+func NewRegistrationStore(opts RegistrationStoreOpts) (*RegistrationStore, error) {
+	dynamicClient, err := dynamic.NewForConfig(opts.RESTConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	objectResolver, err := objects.NewObjectResolver(opts.RESTConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	gvr := v1alpha1.SchemeGroupVersion.WithResource("registrations")
+	inf := dynamicinformer.NewFilteredDynamicInformer(dynamicClient, gvr,
+		"", opts.ResyncInterval, cache.Indexers{}, nil)
+
+	return &RegistrationStore{
+		informer: inf.Informer(),
+		items:    map[string]*registration{},
+		report: func(reg *v1alpha1.Registration, cond metav1.Condition) error {
+			return setCondition(context.Background(), objectResolver, reg, cond)
+		},
+	}, nil
+}
+
+// RegistrationStore holds the loaded Registrations, their filter expressions
+// and payload templates are compiled once when they are added or changed.
+type RegistrationStore struct {
+	informer cache.SharedIndexInformer
+	// report sets the Ready condition on the registration status
+	report func(*v1alpha1.Registration, metav1.Condition) error
+
+	mu       sync.RWMutex
+	items    map[string]*registration
+	onChange []func()
+}
+
+// Run starts the informer and blocks until the stop channel is closed.
+func (s *RegistrationStore) Run(stopCh <-chan struct{}) {
+	s.informer.AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    s.onAdd,
+			UpdateFunc: s.onUpdate,
+			DeleteFunc: s.onDelete,
+		},
+	)
+
+	s.informer.Run(stopCh)
+}
+
+// HasSynced returns true once the initial list of Registrations has been loaded.
+func (s *RegistrationStore) HasSynced() bool {
+	return s.informer.HasSynced()
+}
+
+// List returns all the valid registrations sorted by name.
+func (s *RegistrationStore) List() []*registration {
+	s.mu.RLock()
+	res := make([]*registration, 0, len(s.items))
+	for _, el := range s.items {
+		res = append(res, el)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].name < res[j].name
+	})
+	return res
+}
+
+// FieldSelector returns the field selector of the events accepted by at least one of the
+// registrations, derived from the filter values shared by all of them.
+func (s *RegistrationStore) FieldSelector() fields.Selector {
+	return fieldSelectorOf(s.List())
+}
+
+// OnChange registers a function called whenever a registration is loaded (because
+// added or its spec changed) or removed.
+func (s *RegistrationStore) OnChange(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onChange = append(s.onChange, fn)
+}
+
+// changed calls the functions registered with OnChange.
+func (s *RegistrationStore) changed() {
+	s.mu.RLock()
+	fns := s.onChange
+	s.mu.RUnlock()
+
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// get returns the latest observed Registration.
+func (s *RegistrationStore) get(name string) (*v1alpha1.Registration, bool) {
+	obj, ok, err := s.informer.GetStore().GetByKey(name)
+	if err != nil || !ok {
+		return nil, false
+	}
+	return decodeRegistration(obj)
+}
+
+func (s *RegistrationStore) onAdd(obj interface{}) {
+	reg, ok := decodeRegistration(obj)
+	if !ok {
+		return
+	}
+	s.load(reg)
+}
+
+func (s *RegistrationStore) onUpdate(objOld, objNew interface{}) {
+	reg, ok := decodeRegistration(objNew)
+	if !ok {
+		return
+	}
+
+	// the status updates (i.e. the delivery outcomes) and the resyncs are not loaded
+	// again, unless the Ready condition of the current generation is missing
+	if old, ok := objOld.(*unstructured.Unstructured); ok && old.GetGeneration() == reg.Generation {
+		cond := meta.FindStatusCondition(reg.Status.Conditions, v1alpha1.TypeReady)
+		if cond != nil && cond.ObservedGeneration == reg.Generation {
+			return
+		}
+	}
+	s.load(reg)
+}
+
+func (s *RegistrationStore) onDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	reg, ok := decodeRegistration(obj)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	delete(s.items, reg.Name)
+	s.mu.Unlock()
+
+	klog.V(4).InfoS("registration removed", "registration", reg.Name)
+
+	s.changed()
+}
+
+// load compiles the filter expression and the payload template (only if changed) and
+// stores the registration; the compilation outcome is reported on the registration status.
+func (s *RegistrationStore) load(reg *v1alpha1.Registration) {
+	s.mu.RLock()
+	old := s.items[reg.Name]
+	s.mu.RUnlock()
+
+	res, cond := compileRegistration(reg, old)
+
+	// on conflict the condition is set again as the newer registration is received
+	err := s.report(reg, cond)
+	if apierrors.IsConflict(err) {
+		klog.V(4).InfoS("registration changed, status not updated", "registration", reg.Name)
+	} else if err != nil {
+		klog.ErrorS(err, "unable to update registration status", "registration", reg.Name)
+	}
+
+	defer s.changed()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if res == nil {
+		klog.ErrorS(errors.New(cond.Message), "invalid registration",
+			"registration", reg.Name, "reason", cond.Reason)
+		delete(s.items, reg.Name)
+		return
+	}
+
+	s.items[reg.Name] = res
+
+	klog.V(4).InfoS("registration loaded", "registration", reg.Name)
+}
+
+func decodeRegistration(obj interface{}) (*v1alpha1.Registration, bool) {
+	uns, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		klog.Errorf("unexpected registration object type: %T", obj)
+		return nil, false
+	}
+
+	var reg v1alpha1.Registration
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(uns.Object, &reg)
+	if err != nil {
+		klog.ErrorS(err, "unable to decode registration", "registration", uns.GetName())
+		return nil, false
+	}
+
+	return &reg, true
+}
@@ -0,0 +1,168 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/krateoplatformops/eventrouter/apis/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// newTestStore creates a store not backed by a cluster,
+// recording the conditions reported on the registrations.
+func newTestStore(conds map[string]metav1.Condition) *RegistrationStore {
+	return &RegistrationStore{
+		informer: cache.NewSharedIndexInformer(&cache.ListWatch{},
+			&unstructured.Unstructured{}, 0, cache.Indexers{}),
+		items: map[string]*registration{},
+		report: func(reg *v1alpha1.Registration, cond metav1.Condition) error {
+			conds[reg.Name] = cond
+			return nil
+		},
+	}
+}
+
+func unstructuredRegistration(t *testing.T, name string, spec v1alpha1.RegistrationSpec) *unstructured.Unstructured {
+	t.Helper()
+
+	reg := &v1alpha1.Registration{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha1.SchemeGroupVersion.String(),
+			Kind:       "Registration",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Generation: 1},
+		Spec:       spec,
+	}
+
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &unstructured.Unstructured{Object: obj}
+}
+
+func TestRegistrationStore(t *testing.T) {
+	conds := map[string]metav1.Condition{}
+	s := newTestStore(conds)
+
+	changes := 0
+	s.OnChange(func() { changes++ })
+
+	valid := v1alpha1.RegistrationSpec{
+		ServiceName:      "test",
+		Endpoint:         "http://localhost",
+		FilterExpression: `event.type == "Warning"`,
+		Template:         &v1alpha1.PayloadTemplate{Body: `{{ .Event.Reason }}`},
+	}
+
+	s.onAdd(unstructuredRegistration(t, "foo", valid))
+	s.onAdd(unstructuredRegistration(t, "bar", valid))
+
+	if all := s.List(); len(all) != 2 || all[0].name != "bar" || all[1].name != "foo" {
+		t.Fatalf("expected the registrations sorted by name, got %v", all)
+	}
+	if cond := conds["foo"]; cond.Reason != v1alpha1.ReasonAvailable || cond.Status != metav1.ConditionTrue {
+		t.Errorf("unexpected condition: %+v", cond)
+	}
+	if changes != 2 {
+		t.Errorf("expected 2 changes, got %d", changes)
+	}
+
+	// the compiled expression and template are reused when unchanged
+	old := s.items["foo"]
+	updated := valid
+	updated.Endpoint = "http://localhost:8080"
+	s.onUpdate(nil, unstructuredRegistration(t, "foo", updated))
+
+	if got := s.items["foo"]; got == old || got.program != old.program || got.template != old.template {
+		t.Errorf("expected the compiled expression and template reused")
+	}
+
+	// and compiled again when changed
+	updated.FilterExpression = `event.type == "Normal"`
+	s.onUpdate(nil, unstructuredRegistration(t, "foo", updated))
+
+	if got := s.items["foo"]; got.program == old.program || got.template != old.template {
+		t.Errorf("expected only the expression compiled again")
+	}
+
+	// the status updates are not loaded again
+	status := unstructuredRegistration(t, "foo", updated)
+	unstructured.SetNestedSlice(status.Object, []interface{}{
+		map[string]interface{}{
+			"type":               v1alpha1.TypeReady,
+			"status":             string(metav1.ConditionTrue),
+			"reason":             v1alpha1.ReasonAvailable,
+			"observedGeneration": int64(1),
+		},
+	}, "status", "conditions")
+
+	n, loaded := changes, s.items["foo"]
+	s.onUpdate(unstructuredRegistration(t, "foo", updated), status)
+	if changes != n || s.items["foo"] != loaded {
+		t.Errorf("expected a status update not loaded")
+	}
+
+	// unless the Ready condition is missing
+	s.onUpdate(status, unstructuredRegistration(t, "foo", updated))
+	if changes != n+1 {
+		t.Errorf("expected a registration without the Ready condition loaded")
+	}
+
+	// an invalid registration is removed
+	invalid := valid
+	invalid.Template = &v1alpha1.PayloadTemplate{Body: `{{ .Event.Reason `}
+	s.onUpdate(nil, unstructuredRegistration(t, "foo", invalid))
+
+	if _, ok := s.items["foo"]; ok {
+		t.Errorf("expected the invalid registration removed")
+	}
+	if cond := conds["foo"]; cond.Reason != v1alpha1.ReasonInvalidTemplate || cond.Status != metav1.ConditionFalse {
+		t.Errorf("unexpected condition: %+v", cond)
+	}
+
+	invalid = valid
+	invalid.FilterExpression = `event.type ==`
+	s.onUpdate(nil, unstructuredRegistration(t, "bar", invalid))
+
+	if cond := conds["bar"]; cond.Reason != v1alpha1.ReasonInvalidFilterExpression {
+		t.Errorf("unexpected condition: %+v", cond)
+	}
+	if n := len(s.List()); n != 0 {
+		t.Errorf("expected no valid registrations, got %d", n)
+	}
+
+	// deleted, also when the final state is unknown
+	s.onAdd(unstructuredRegistration(t, "foo", valid))
+	s.onAdd(unstructuredRegistration(t, "bar", valid))
+	s.onDelete(unstructuredRegistration(t, "foo", valid))
+	s.onDelete(cache.DeletedFinalStateUnknown{Key: "bar", Obj: unstructuredRegistration(t, "bar", valid)})
+
+	if n := len(s.List()); n != 0 {
+		t.Errorf("expected the registrations removed, got %d", n)
+	}
+}
+
+func TestDecodeRegistration(t *testing.T) {
+	obj := unstructuredRegistration(t, "foo", v1alpha1.RegistrationSpec{
+		ServiceName: "test",
+		Endpoint:    "http://localhost",
+		Filter:      &v1alpha1.RegistrationFilter{Types: []string{"Warning"}},
+	})
+
+	reg, ok := decodeRegistration(obj)
+	if !ok || reg.Name != "foo" || reg.Spec.Endpoint != "http://localhost" || reg.Spec.Filter.Types[0] != "Warning" {
+		t.Errorf("unexpected registration: %+v", reg)
+	}
+
+	if _, ok := decodeRegistration(&v1alpha1.Registration{}); ok {
+		t.Errorf("expected typed objects not decoded")
+	}
+
+	obj.Object["spec"] = "invalid"
+	if _, ok := decodeRegistration(obj); ok {
+		t.Errorf("expected an invalid registration not decoded")
+	}
+}
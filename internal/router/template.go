@@ -0,0 +1,181 @@
+package router
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// templateData is what the payload templates are rendered against.
+type templateData struct {
+	Event         *corev1.Event
+	CompositionID string
+	// CompositionSource is the object labelled with the composition id,
+	// the involved object or one of its owners
+	CompositionSource string
+	// Correlations are the correlation keys found on the composition source,
+	// by CorrelationKey.String()
+	Correlations   map[string]string
+	InvolvedObject objectMeta
+}
+
+// objectMeta is the involved object metadata.
+type objectMeta struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	UID        types.UID
+	Labels     map[string]string
+}
+
+func newTemplateData(evt *corev1.Event, compositionId string, objectLabels map[string]string) *templateData {
+	ref := evt.InvolvedObject
+	return &templateData{
+		Event:             evt,
+		CompositionID:     compositionId,
+		CompositionSource: evt.GetAnnotations()[keyCompositionSource],
+		Correlations:      eventCorrelations(evt.GetAnnotations()),
+		InvolvedObject: objectMeta{
+			APIVersion: ref.APIVersion,
+			Kind:       ref.Kind,
+			Namespace:  ref.Namespace,
+			Name:       ref.Name,
+			UID:        ref.UID,
+			Labels:     objectLabels,
+		},
+	}
+}
+
+// compileTemplate parses a payload template.
+func compileTemplate(name, body string) (*template.Template, error) {
+	return template.New(name).
+		Option("missingkey=zero").
+		Funcs(templateFuncs()).
+		Parse(body)
+}
+
+// renderTemplate executes the template against the data.
+func renderTemplate(tpl *template.Template, data *templateData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// templateFuncs is a small subset of the sprig template functions.
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"toJson": func(v any) (string, error) {
+			dat, err := json.Marshal(v)
+			return string(dat), err
+		},
+		"toPrettyJson": func(v any) (string, error) {
+			dat, err := json.MarshalIndent(v, "", "  ")
+			return string(dat), err
+		},
+		"quote": func(v any) string {
+			return fmt.Sprintf("%q", toString(v))
+		},
+		"default": func(def, v any) any {
+			if empty(v) {
+				return def
+			}
+			return v
+		},
+		"empty":      empty,
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+		"join": func(sep string, v []string) string {
+			return strings.Join(v, sep)
+		},
+		"trunc": func(n int, s string) string {
+			if n >= 0 && len(s) > n {
+				return s[:n]
+			}
+			return s
+		},
+		"b64enc": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"b64dec": func(s string) (string, error) {
+			dat, err := base64.StdEncoding.DecodeString(s)
+			return string(dat), err
+		},
+		"now": time.Now,
+		"date": func(layout string, v any) string {
+			t, ok := asTime(v)
+			if !ok || t.IsZero() {
+				return ""
+			}
+			return t.Format(layout)
+		},
+	}
+}
+
+func toString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// empty reports whether the value is the zero value of its type.
+func empty(v any) bool {
+	if v == nil {
+		return true
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
+
+// asTime extracts the time from the standard and the Kubernetes time types.
+func asTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case metav1.Time:
+		return t.Time, true
+	case *metav1.Time:
+		if t == nil {
+			return time.Time{}, false
+		}
+		return t.Time, true
+	case metav1.MicroTime:
+		return t.Time, true
+	case *metav1.MicroTime:
+		if t == nil {
+			return time.Time{}, false
+		}
+		return t.Time, true
+	}
+	return time.Time{}, false
+}
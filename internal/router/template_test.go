@@ -0,0 +1,73 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCompileTemplateInvalid(t *testing.T) {
+	if _, err := compileTemplate("test", `{{ .Event.Reason `); err == nil {
+		t.Error("expected error with unterminated action")
+	}
+
+	if _, err := compileTemplate("test", `{{ unknownFunc .Event }}`); err == nil {
+		t.Error("expected error with undefined function")
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	evt := &corev1.Event{
+		Type:          corev1.EventTypeWarning,
+		Reason:        "CannotCreateExternalResource",
+		Message:       `cannot create "test-1-ng"`,
+		LastTimestamp: metav1.NewTime(time.Date(2022, 10, 26, 15, 25, 12, 0, time.UTC)),
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				keyCompositionSource: "/apis/apps/v1/namespaces/demo/deployment/web",
+				keyCorrelations:      `{"annotation:meta.helm.sh/release-name":"web-release"}`,
+			},
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind: "NodeGroup",
+			Name: "test-1-ng",
+		},
+	}
+
+	data := newTemplateData(evt, "abcde12345", map[string]string{"team": "platform"})
+
+	table := []struct {
+		body string
+		want string
+	}{
+		{`{{ .CompositionID }}`, `abcde12345`},
+		{`{{ .CompositionSource }}`, `/apis/apps/v1/namespaces/demo/deployment/web`},
+		{`{{ index .Correlations "annotation:meta.helm.sh/release-name" }}`, `web-release`},
+		{`{{ .InvolvedObject.Labels.team }}`, `platform`},
+		{`{{ .InvolvedObject.Labels.missing }}`, ``},
+		{`{{ .Event.Message | toJson }}`, `"cannot create \"test-1-ng\""`},
+		{`{{ .Event.Type | lower }}`, `warning`},
+		{`{{ .Event.Action | default "none" }}`, `none`},
+		{`{{ .Event.Reason | trunc 6 }}`, `Cannot`},
+		{`{{ date "2006-01-02" .Event.LastTimestamp }}`, `2022-10-26`},
+		{`{{ split "-" .InvolvedObject.Name | join "_" }}`, `test_1_ng`},
+	}
+
+	for _, tc := range table {
+		tpl, err := compileTemplate("test", tc.body)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.body, err)
+		}
+
+		got, err := renderTemplate(tpl, data)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.body, err)
+		}
+
+		if string(got) != tc.want {
+			t.Errorf("%s: expected %q, got %q", tc.body, tc.want, got)
+		}
+	}
+}
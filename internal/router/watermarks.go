@@ -0,0 +1,387 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// watermarkGrace is how long the handled events are tracked by
+	// id, before the watermark, not to miss the ones out of order
+	watermarkGrace = 5 * time.Minute
+	// maxHandled bounds the handled events tracked by id, shared
+	// by all the registrations, so that the checkpoint fits in a
+	// ConfigMap; every registration tracks at least minHandled
+	maxHandled = 5000
+	minHandled = 100
+)
+
+type WatermarksOpts struct {
+	RESTConfig    *rest.Config
+	Registrations *RegistrationStore
+	// Namespace and Name of the ConfigMap holding the watermarks.
+	Namespace string
+	Name      string
+	// Interval between two checkpoints.
+	Interval time.Duration
+}
+
+// NewWatermarks creates the per registration delivery watermarks
+// checkpointed on a ConfigMap.
+func NewWatermarks(opts WatermarksOpts) (*Watermarks, error) {
+	cs, err := kubernetes.NewForConfig(opts.RESTConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	return &Watermarks{
+		configMaps:    cs.CoreV1().ConfigMaps(opts.Namespace),
+		registrations: opts.Registrations,
+		name:          opts.Name,
+		interval:      interval,
+		started:       time.Now(),
+		marks:         map[string]*watermark{},
+	}, nil
+}
+
+// Watermarks track, for every registration, the events already handled so that after
+// a restart (or a leader change) only the events missed in the meantime are notified.
+// Events are handled once notified or, after all the retries, dead-lettered.
+//
+// A watermark is a time before which all the events have been handled, along with
+// the events handled after it; it does not move beyond the oldest event in flight
+// and trails the newest handled one by watermarkGrace.
+type Watermarks struct {
+	configMaps    corev1client.ConfigMapInterface
+	registrations *RegistrationStore
+	name          string
+	interval      time.Duration
+
+	mu sync.Mutex
+	// started is when the watermarks have been loaded, it's the watermark
+	// of the registrations without a checkpoint yet
+	started time.Time
+	// resourceVersion is the one of the loaded ConfigMap, it
+	// prevents overwriting the checkpoints of another replica
+	resourceVersion string
+	marks           map[string]*watermark
+	dirty           bool
+}
+
+// watermark is the checkpoint of a registration.
+type watermark struct {
+	Time time.Time `json:"time"`
+	// Handled are the ids of the events, at or after Time, already handled.
+	Handled map[string]time.Time `json:"handled,omitempty"`
+
+	// pending are the events in flight
+	pending map[string]time.Time
+}
+
+// Run checkpoints the watermarks every interval until the stop channel is closed.
+func (w *Watermarks) Run(stopCh <-chan struct{}) {
+	wait.Until(w.flush, w.interval, stopCh)
+	w.flush()
+}
+
+// Load reads the checkpointed watermarks, discarding the ones in memory.
+func (w *Watermarks) Load(ctx context.Context) error {
+	marks := map[string]*watermark{}
+
+	cm, err := w.configMaps.Get(ctx, w.name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	rv := ""
+	if err == nil {
+		rv = cm.ResourceVersion
+		for name, dat := range cm.Data {
+			wm := &watermark{}
+			if err := json.Unmarshal([]byte(dat), wm); err != nil {
+				klog.ErrorS(err, "ignoring invalid watermark", "registration", name)
+				continue
+			}
+			marks[name] = wm
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.started = time.Now()
+	w.resourceVersion = rv
+	w.marks = marks
+	w.dirty = false
+
+	klog.InfoS("watermarks loaded", "configMap", w.name, "registrations", len(marks))
+	return nil
+}
+
+// handled reports whether the event has already been handled for the registration. Only the events
+// happened before the watermarks were loaded, the ones replayed after a restart, are checked: the live
+// ones, even if received out of order, are never skipped. The registrations without a checkpoint yet
+// have handled everything before the watermarks were loaded.
+func (w *Watermarks) handled(reg *registration, evt *corev1.Event) bool {
+	if w == nil {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	ts := eventTime(evt)
+	if !ts.Before(w.started) {
+		return false
+	}
+
+	wm, ok := w.marks[reg.name]
+	if !ok {
+		return true
+	}
+
+	if _, ok := wm.Handled[cloudEventID(evt)]; ok {
+		return true
+	}
+	return ts.Before(wm.Time)
+}
+
+// handledAll reports whether the event has already been handled for all the registrations.
+func (w *Watermarks) handledAll(all []*registration, evt *corev1.Event) bool {
+	if w == nil {
+		return false
+	}
+
+	for _, reg := range all {
+		if !w.handled(reg, evt) {
+			return false
+		}
+	}
+	return true
+}
+
+// begin tracks the event as in flight for the registration.
+func (w *Watermarks) begin(name string, evt *corev1.Event) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	wm := w.mark(name)
+	wm.pending[cloudEventID(evt)] = eventTime(evt)
+}
+
+// done tracks the event as handled for the registration.
+func (w *Watermarks) done(name string, evt *corev1.Event) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	id, ts := cloudEventID(evt), eventTime(evt)
+
+	wm := w.mark(name)
+	delete(wm.pending, id)
+	if !ts.Before(wm.Time) {
+		wm.Handled[id] = ts
+	}
+	w.dirty = true
+}
+
+// mark returns the registration watermark, creating it if missing;
+// the caller must hold the lock.
+func (w *Watermarks) mark(name string) *watermark {
+	wm, ok := w.marks[name]
+	if !ok {
+		wm = &watermark{Time: w.started}
+		w.marks[name] = wm
+	}
+	if wm.Handled == nil {
+		wm.Handled = map[string]time.Time{}
+	}
+	if wm.pending == nil {
+		wm.pending = map[string]time.Time{}
+	}
+	return wm
+}
+
+// advance moves the watermark up to the oldest event in flight or, if none, to the
+// newest handled one, less watermarkGrace so that the events received out of order
+// are still tracked by id; the handled events before it are dropped and, beyond
+// limit, the oldest ones too, moving the watermark after them; it returns how many
+// handled events were dropped because of the limit.
+func (wm *watermark) advance(limit int) (dropped int) {
+	var floor time.Time
+	for _, ts := range wm.pending {
+		if floor.IsZero() || ts.Before(floor) {
+			floor = ts
+		}
+	}
+
+	if floor.IsZero() {
+		for _, ts := range wm.Handled {
+			if ts.After(floor) {
+				floor = ts
+			}
+		}
+	}
+
+	if !floor.IsZero() {
+		floor = floor.Add(-watermarkGrace)
+	}
+
+	if floor.After(wm.Time) {
+		wm.Time = floor
+	}
+	wm.prune()
+
+	if len(wm.Handled) > limit {
+		all := make([]time.Time, 0, len(wm.Handled))
+		for _, ts := range wm.Handled {
+			all = append(all, ts)
+		}
+		sort.Slice(all, func(i, j int) bool { return all[i].Before(all[j]) })
+
+		if ts := all[len(all)-limit-1].Add(time.Nanosecond); ts.After(wm.Time) {
+			wm.Time = ts
+		}
+		dropped = len(wm.Handled)
+		wm.prune()
+		dropped -= len(wm.Handled)
+	}
+	return dropped
+}
+
+// prune drops the handled events before the watermark.
+func (wm *watermark) prune() {
+	for id, ts := range wm.Handled {
+		if ts.Before(wm.Time) {
+			delete(wm.Handled, id)
+		}
+	}
+}
+
+func (w *Watermarks) flush() {
+	w.mu.Lock()
+	if !w.dirty {
+		w.mu.Unlock()
+		return
+	}
+
+	// the checkpoint must fit in a ConfigMap
+	limit := maxHandled
+	if len(w.marks) > 0 {
+		limit = max(maxHandled/len(w.marks), minHandled)
+	}
+
+	data := make(map[string]string, len(w.marks))
+	for name, wm := range w.marks {
+		// drops the watermarks of the deleted registrations
+		if w.registrations != nil {
+			if _, ok := w.registrations.get(name); !ok {
+				delete(w.marks, name)
+				continue
+			}
+		}
+
+		if n := wm.advance(limit); n > 0 {
+			klog.InfoS("checkpoint limit reached, older handled events dropped",
+				"registration", name, "dropped", n, "watermark", wm.Time)
+		}
+
+		dat, err := json.Marshal(wm)
+		if err != nil {
+			klog.ErrorS(err, "unable to encode watermark", "registration", name)
+			continue
+		}
+		data[name] = string(dat)
+	}
+	rv := w.resourceVersion
+	w.dirty = false
+	w.mu.Unlock()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            w.name,
+			ResourceVersion: rv,
+		},
+		Data: data,
+	}
+
+	var err error
+	if len(rv) == 0 {
+		cm, err = w.configMaps.Create(context.Background(), cm, metav1.CreateOptions{})
+	} else {
+		cm, err = w.configMaps.Update(context.Background(), cm, metav1.UpdateOptions{})
+	}
+	if apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) {
+		klog.InfoS("watermarks checkpointed by another replica, merging", "configMap", w.name)
+		if err := w.merge(context.Background()); err != nil {
+			klog.ErrorS(err, "unable to merge watermarks", "configMap", w.name)
+		}
+		return
+	}
+	if err != nil {
+		klog.ErrorS(err, "unable to checkpoint watermarks", "configMap", w.name)
+		w.mu.Lock()
+		w.dirty = true
+		w.mu.Unlock()
+		return
+	}
+
+	w.mu.Lock()
+	w.resourceVersion = cm.ResourceVersion
+	w.mu.Unlock()
+}
+
+// merge reads the watermarks checkpointed by another replica and merges them
+// with the ones in memory, they will be written on the next checkpoint.
+func (w *Watermarks) merge(ctx context.Context) error {
+	cm, err := w.configMaps.Get(ctx, w.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for name, dat := range cm.Data {
+		other := &watermark{}
+		if err := json.Unmarshal([]byte(dat), other); err != nil {
+			continue
+		}
+
+		wm := w.mark(name)
+		if other.Time.After(wm.Time) {
+			wm.Time = other.Time
+		}
+		for id, ts := range other.Handled {
+			wm.Handled[id] = ts
+		}
+	}
+
+	w.resourceVersion = cm.ResourceVersion
+	w.dirty = true
+	return nil
+}
@@ -0,0 +1,146 @@
+package router
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func watermarkEvent(uid string, ts time.Time) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:             types.UID(uid),
+			ResourceVersion: "1",
+		},
+		LastTimestamp: metav1.NewTime(ts),
+	}
+}
+
+func newTestWatermarks(started time.Time) *Watermarks {
+	return &Watermarks{
+		configMaps: fake.NewSimpleClientset().CoreV1().ConfigMaps("demo-system"),
+		name:       "eventrouter-watermarks",
+		started:    started,
+		marks:      map[string]*watermark{},
+	}
+}
+
+func TestWatermarksWithoutCheckpoint(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	w := newTestWatermarks(now)
+
+	reg := &registration{name: "test", created: now.Add(-time.Hour)}
+	if !w.handled(reg, watermarkEvent("a", now.Add(-time.Minute))) {
+		t.Error("expected events before startup handled")
+	}
+	if w.handled(reg, watermarkEvent("b", now.Add(time.Second))) {
+		t.Error("expected events after startup not handled")
+	}
+}
+
+func TestWatermarksOutOfOrder(t *testing.T) {
+	start := time.Now().Truncate(time.Second).Add(-time.Hour)
+	w := newTestWatermarks(start)
+	reg := &registration{name: "test", created: start.Add(-time.Hour)}
+
+	a := watermarkEvent("a", start.Add(102*time.Second))
+	b := watermarkEvent("b", start.Add(100*time.Second))
+
+	w.begin(reg.name, a)
+	w.done(reg.name, a)
+	w.flush()
+
+	// live events are never skipped
+	if w.handled(reg, b) {
+		t.Fatal("expected a live event received out of order not handled")
+	}
+
+	// neither when replayed after a restart
+	r := newTestWatermarks(time.Now())
+	r.configMaps = w.configMaps
+	if err := r.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !r.handled(reg, a) {
+		t.Error("expected the handled event skipped on replay")
+	}
+	if r.handled(reg, b) {
+		t.Error("expected the event received out of order not skipped on replay")
+	}
+}
+
+func TestWatermarksLimit(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+	wm := &watermark{Handled: map[string]time.Time{}}
+	for i := 0; i < 10; i++ {
+		wm.Handled[string(rune('a'+i))] = start.Add(time.Duration(i) * time.Hour)
+	}
+	wm.pending = map[string]time.Time{"z": start}
+
+	if n := wm.advance(4); n != 6 {
+		t.Errorf("expected 6 handled events dropped, got %d", n)
+	}
+
+	if len(wm.Handled) != 4 {
+		t.Fatalf("expected 4 handled events tracked, got %d", len(wm.Handled))
+	}
+	if !wm.Time.After(start.Add(5*time.Hour)) || wm.Time.After(start.Add(6*time.Hour)) {
+		t.Errorf("expected the watermark after the dropped events, got %v", wm.Time)
+	}
+}
+
+func TestWatermarksCheckpoint(t *testing.T) {
+	start := time.Now().Truncate(time.Second).Add(-time.Hour)
+	w := newTestWatermarks(start)
+	reg := &registration{name: "test", created: start.Add(-time.Hour)}
+
+	e1 := watermarkEvent("e1", start.Add(1*time.Second))
+	e2 := watermarkEvent("e2", start.Add(2*time.Second))
+	e3 := watermarkEvent("e3", start.Add(3*time.Second))
+	e3bis := watermarkEvent("e3bis", start.Add(3*time.Second))
+
+	for _, el := range []*corev1.Event{e1, e2, e3} {
+		w.begin(reg.name, el)
+	}
+	// e2 is still in flight
+	w.done(reg.name, e1)
+	w.done(reg.name, e3)
+	w.flush()
+
+	// simulates a restart
+	r := newTestWatermarks(time.Now())
+	r.configMaps = w.configMaps
+	if err := r.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	table := []struct {
+		evt     *corev1.Event
+		handled bool
+	}{
+		{e1, true},
+		{e2, false},
+		{e3, true},
+		{e3bis, false},
+	}
+
+	for _, tc := range table {
+		if got := r.handled(reg, tc.evt); got != tc.handled {
+			t.Errorf("%s: expected handled %v, got %v", tc.evt.UID, tc.handled, got)
+		}
+	}
+
+	// once e2 is done the handled events within the grace are still tracked
+	w.done(reg.name, e2)
+	w.flush()
+
+	wm := w.marks[reg.name]
+	if wm.Time.After(e1.LastTimestamp.Time) || len(wm.Handled) != 3 {
+		t.Errorf("unexpected watermark: %+v", wm)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net/http"
@@ -10,12 +11,23 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/krateoplatformops/eventrouter/internal/deadletter"
 	"github.com/krateoplatformops/eventrouter/internal/env"
+	"github.com/krateoplatformops/eventrouter/internal/events"
+	"github.com/krateoplatformops/eventrouter/internal/health"
 	httputil "github.com/krateoplatformops/eventrouter/internal/helpers/http"
 	"github.com/krateoplatformops/eventrouter/internal/helpers/queue"
+	"github.com/krateoplatformops/eventrouter/internal/leader"
+	"github.com/krateoplatformops/eventrouter/internal/metrics"
+	"github.com/krateoplatformops/eventrouter/internal/objects"
+	"github.com/krateoplatformops/eventrouter/internal/refs"
 	"github.com/krateoplatformops/eventrouter/internal/router"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
 )
@@ -37,15 +49,85 @@ func main() {
 	insecure := flag.Bool("insecure", env.Bool("EVENT_ROUTER_INSECURE", false),
 		"allow insecure server connections when using SSL")
 	resyncInterval := flag.Duration("resync-interval",
-		env.Duration("EVENT_ROUTER_RESYNC_INTERVAL", time.Minute*3), "resync interval")
+		env.Duration("EVENT_ROUTER_RESYNC_INTERVAL", time.Minute*3), "resync interval of the informers, unchanged events are not notified again (0 disables the resync)")
 	throttlePeriod := flag.Duration("throttle-period",
 		env.Duration("EVENT_ROUTER_THROTTLE_PERIOD", 0), "throttle period")
 	namespace := flag.String("namespace",
-		env.String("EVENT_ROUTER_NAMESPACE", ""), "namespace to list and watch")
+		env.String("EVENT_ROUTER_NAMESPACE", ""), "comma separated list of namespaces to list and watch, all when empty and no namespace selector is set")
+	namespaceSelector := flag.String("namespace-selector",
+		env.String("EVENT_ROUTER_NAMESPACE_SELECTOR", ""), "label selector of the namespaces to list and watch, in addition to the ones listed by -namespace")
+	fieldSelector := flag.String("field-selector",
+		env.String("EVENT_ROUTER_FIELD_SELECTOR", ""), "field selector of the watched events, by core/v1 Events keys (i.e. 'type=Warning,involvedObject.kind=Pod')")
+	fieldSelectorFromRegistrations := flag.Bool("field-selector-from-registrations",
+		env.Bool("EVENT_ROUTER_FIELD_SELECTOR_FROM_REGISTRATIONS", false), "narrow the field selector of the watched events to the filter values shared by all the registrations")
+	eventsAPI := flag.String("events-api",
+		env.String("EVENT_ROUTER_EVENTS_API", string(events.APICore)), "events API to watch: 'core' (core/v1), 'events' (events.k8s.io/v1) or 'both'")
+	ownerReferencesDepth := flag.Int("owner-references-depth",
+		env.Int("EVENT_ROUTER_OWNER_REFERENCES_DEPTH", 5), "how many owner levels are walked looking for the composition id of an involved object not labelled with it (0 disables the walk)")
+	correlationKeys := flag.String("correlation-keys",
+		env.String("EVENT_ROUTER_CORRELATION_KEYS", "label:krateo.io/composition-id"), "comma separated, ordered list of the labels and annotations correlating the objects to a composition (i.e. 'label:krateo.io/composition-id,annotation:meta.helm.sh/release-name')")
+	stampEvents := flag.Bool("stamp-events",
+		env.Bool("EVENT_ROUTER_STAMP_EVENTS", false), "patch the resolved composition id label onto the events, so that they can be selected by composition and their updates need no resolution")
+	objectCacheTTL := flag.Duration("object-cache-ttl",
+		env.Duration("EVENT_ROUTER_OBJECT_CACHE_TTL", time.Minute), "time the metadata of the resolved involved objects and owners is cached (0 disables the cache)")
+	objectCacheSize := flag.Int("object-cache-size",
+		env.Int("EVENT_ROUTER_OBJECT_CACHE_SIZE", 4096), "maximum number of objects in the metadata cache")
+	objectInformers := flag.Bool("object-informers",
+		env.Bool("EVENT_ROUTER_OBJECT_INFORMERS", false), "watch the metadata of the objects of every observed kind, serving their labels locally")
+	objectInformersKinds := flag.String("object-informers-kinds",
+		env.String("EVENT_ROUTER_OBJECT_INFORMERS_KINDS", ""), "comma separated glob patterns of the API groups, or API groups and kinds, of the objects watched by the informers (i.e. '*.krateo.io,apps/Deployment'), all when empty")
+	objectInformersMax := flag.Int("object-informers-max",
+		env.Int("EVENT_ROUTER_OBJECT_INFORMERS_MAX", 32), "maximum number of kinds watched by the informers, the other ones are served by the cache (0 for no limit)")
+	acceptObjects := flag.String("accept-objects",
+		env.String("EVENT_ROUTER_ACCEPT_OBJECTS", ""), "comma separated glob patterns of the accepted involved objects API groups, or API groups and kinds (i.e. '*.krateo.io,*.crossplane.io,apps/Deployment'), all when empty")
+	denyObjects := flag.String("deny-objects",
+		env.String("EVENT_ROUTER_DENY_OBJECTS", ""), "comma separated glob patterns of the rejected involved objects API groups, or API groups and kinds (i.e. 'core/Pod')")
+	acceptObjectsFile := flag.String("accept-objects-file",
+		env.String("EVENT_ROUTER_ACCEPT_OBJECTS_FILE", ""), "optional YAML file with the 'allow' and 'deny' patterns of the involved objects, overriding the flags and reloaded when changed")
+	acceptObjectsReloadInterval := flag.Duration("accept-objects-reload-interval",
+		env.Duration("EVENT_ROUTER_ACCEPT_OBJECTS_RELOAD_INTERVAL", 30*time.Second), "interval between two checks of the accept objects file")
+	refsNamespaces := flag.String("refs-namespaces",
+		env.String("EVENT_ROUTER_REFS_NAMESPACES", env.String("POD_NAMESPACE", "")), "comma separated list of the namespaces of the Secrets and ConfigMaps the registrations can reference, '*' for all")
 	queueMaxCapacity := flag.Int("queue-max-capacity",
 		env.Int("EVENT_ROUTER_QUEUE_MAX_CAPACITY", 10), "notification queue buffer size")
 	queueWorkerThreads := flag.Int("queue-worker-threads",
 		env.Int("EVENT_ROUTER_QUEUE_WORKER_THREADS", 50), "number of worker threads in the notification queue")
+	deliveryRetries := flag.Int("delivery-retries",
+		env.Int("EVENT_ROUTER_DELIVERY_RETRIES", 5), "number of retries for a failed notification")
+	deliveryBackoff := flag.Duration("delivery-backoff",
+		env.Duration("EVENT_ROUTER_DELIVERY_BACKOFF", time.Second), "initial delay between notification retries")
+	deliveryBackoffMax := flag.Duration("delivery-backoff-max",
+		env.Duration("EVENT_ROUTER_DELIVERY_BACKOFF_MAX", time.Minute), "maximum delay between notification retries")
+	deadLetterSize := flag.Int("dead-letter-size",
+		env.Int("EVENT_ROUTER_DEAD_LETTER_SIZE", 100), "number of undelivered notifications kept in memory")
+	deadLetterFile := flag.String("dead-letter-file",
+		env.String("EVENT_ROUTER_DEAD_LETTER_FILE", ""), "optional file where undelivered notifications are appended")
+	statusUpdateInterval := flag.Duration("status-update-interval",
+		env.Duration("EVENT_ROUTER_STATUS_UPDATE_INTERVAL", 30*time.Second), "minimum interval between registration status updates")
+	metricsAddress := flag.String("metrics-address",
+		env.String("EVENT_ROUTER_METRICS_ADDRESS", ":8080"), "address the metrics endpoint binds to, empty to disable")
+	healthAddress := flag.String("health-address",
+		env.String("EVENT_ROUTER_HEALTH_ADDRESS", ":8081"), "address the health and readiness probes bind to, empty to disable")
+	queueStallTimeout := flag.Duration("queue-stall-timeout",
+		env.Duration("EVENT_ROUTER_QUEUE_STALL_TIMEOUT", 10*time.Minute), "maximum time the notification queue can go without dispatching or completing a job, while jobs are waiting, before being reported as unhealthy")
+	leaderElect := flag.Bool("leader-elect",
+		env.Bool("EVENT_ROUTER_LEADER_ELECT", false), "enable leader election, only the leader replica delivers the notifications")
+	leaderElectNamespace := flag.String("leader-elect-namespace",
+		env.String("EVENT_ROUTER_LEADER_ELECT_NAMESPACE", env.String("POD_NAMESPACE", "")), "namespace of the leader election lease")
+	leaderElectLeaseName := flag.String("leader-elect-lease-name",
+		env.String("EVENT_ROUTER_LEADER_ELECT_LEASE_NAME", "eventrouter"), "name of the leader election lease")
+	leaderElectLeaseDuration := flag.Duration("leader-elect-lease-duration",
+		env.Duration("EVENT_ROUTER_LEADER_ELECT_LEASE_DURATION", 15*time.Second), "time the standby replicas wait before acquiring a not renewed lease")
+	leaderElectRenewDeadline := flag.Duration("leader-elect-renew-deadline",
+		env.Duration("EVENT_ROUTER_LEADER_ELECT_RENEW_DEADLINE", 10*time.Second), "time the leader retries to renew the lease before giving up the leadership")
+	leaderElectRetryPeriod := flag.Duration("leader-elect-retry-period",
+		env.Duration("EVENT_ROUTER_LEADER_ELECT_RETRY_PERIOD", 2*time.Second), "time between the leader election attempts")
+	checkpointConfigMap := flag.String("checkpoint-configmap",
+		env.String("EVENT_ROUTER_CHECKPOINT_CONFIGMAP", "eventrouter-watermarks"), "name of the ConfigMap holding the delivery watermarks, empty to disable")
+	checkpointNamespace := flag.String("checkpoint-namespace",
+		env.String("EVENT_ROUTER_CHECKPOINT_NAMESPACE", env.String("POD_NAMESPACE", "")), "namespace of the delivery watermarks ConfigMap, empty to disable")
+	checkpointInterval := flag.Duration("checkpoint-interval",
+		env.Duration("EVENT_ROUTER_CHECKPOINT_INTERVAL", 10*time.Second), "interval between the delivery watermarks checkpoints")
 
 	flag.Usage = func() {
 		fmt.Fprintln(flag.CommandLine.Output(), "Flags:")
@@ -56,9 +138,40 @@ func main() {
 
 	flag.Parse()
 
+	api, ok := events.ParseAPI(*eventsAPI)
+	if !ok {
+		klog.Fatalf("invalid events API '%s', must be one of 'core', 'events' or 'both'", *eventsAPI)
+	}
+
+	fieldSel, err := events.ParseFieldSelector(*fieldSelector)
+	if err != nil {
+		klog.Fatalf("invalid field selector '%s': %s", *fieldSelector, err.Error())
+	}
+
+	corrKeys, err := router.ParseCorrelationKeys(*correlationKeys)
+	if err != nil {
+		klog.Fatalf("invalid correlation keys: %s", err.Error())
+	}
+
+	acceptor, err := objects.NewAcceptor(objects.AcceptorOpts{
+		Rules: objects.AcceptRules{
+			Allow: objects.ParsePatterns(*acceptObjects),
+			Deny:  objects.ParsePatterns(*denyObjects),
+		},
+		File:     *acceptObjectsFile,
+		Interval: *acceptObjectsReloadInterval,
+	})
+	if err != nil {
+		klog.Fatalf("invalid accept objects rules: %s", err.Error())
+	}
+
+	nsSelector, err := labels.Parse(*namespaceSelector)
+	if err != nil {
+		klog.Fatalf("invalid namespace selector '%s': %s", *namespaceSelector, err.Error())
+	}
+
 	// Kubernetes configuration
 	var cfg *rest.Config
-	var err error
 	if len(*kubeconfig) > 0 {
 		cfg, err = clientcmd.BuildConfigFromFlags("", *kubeconfig)
 	} else {
@@ -83,30 +196,259 @@ func main() {
 	// setup notification worker queue
 	q := queue.NewQueue(*queueMaxCapacity, *queueWorkerThreads)
 	q.Run()
-	defer q.Terminate()
+
+	stop := sigHandler()
+
+	// expose the metrics
+	if len(*metricsAddress) > 0 {
+		metrics.RegisterQueue(q)
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		go httputil.Serve(httputil.ServerOpts{
+			Name:    "metrics",
+			Address: *metricsAddress,
+			Handler: mux,
+		}, stop)
+	}
+
+	// expose the health and readiness probes
+	checker := health.NewChecker()
+	checker.AddLivenessCheck("queue-dispatcher", func() error {
+		if q.Stalled(*queueStallTimeout) {
+			return fmt.Errorf("no job dispatched or completed for more than %s", *queueStallTimeout)
+		}
+		return nil
+	})
+	checker.AddReadinessCheck("queue", func() error {
+		if n, size := q.GetJobCount(), q.GetCapacity(); n >= size {
+			return fmt.Errorf("saturated (%d/%d)", n, size)
+		}
+		return nil
+	})
+	if len(*healthAddress) > 0 {
+		go httputil.Serve(httputil.ServerOpts{
+			Name:    "health",
+			Address: *healthAddress,
+			Handler: checker.Handler(),
+		}, stop)
+	}
+
+	// setup the registrations store and wait for the initial list
+	registrations, err := router.NewRegistrationStore(router.RegistrationStoreOpts{
+		RESTConfig:     cfg,
+		ResyncInterval: *resyncInterval,
+	})
+	if err != nil {
+		klog.Fatalf("unable to create the registrations store: %s", err.Error())
+	}
+	go registrations.Run(stop)
+	checker.AddReadinessCheck("registrations", synced(registrations.HasSynced))
+
+	if !cache.WaitForCacheSync(stop, registrations.HasSynced) {
+		klog.Fatalf("unable to sync the registrations store")
+	}
+
+	// setup the registrations delivery status recorder
+	recorder, err := router.NewStatusRecorder(router.StatusRecorderOpts{
+		RESTConfig:    cfg,
+		Registrations: registrations,
+		Interval:      *statusUpdateInterval,
+	})
+	if err != nil {
+		klog.Fatalf("unable to create the registrations status recorder: %s", err.Error())
+	}
+	go recorder.Run(stop)
+
+	// setup the resolver of the values referenced by the registrations
+	refsResolver, err := refs.NewResolver(refs.ResolverOpts{
+		RESTConfig:     cfg,
+		ResyncInterval: *resyncInterval,
+		Namespaces:     router.ParseNamespaces(*refsNamespaces),
+	})
+	if err != nil {
+		klog.Fatalf("unable to create the references resolver: %s", err.Error())
+	}
+	go refsResolver.Run(stop)
+
+	var wg sync.WaitGroup
+
+	// setup the delivery watermarks, so that after a restart
+	// only the events missed in the meantime are notified
+	var watermarks *router.Watermarks
+	if len(*checkpointConfigMap) > 0 && len(*checkpointNamespace) > 0 {
+		watermarks, err = router.NewWatermarks(router.WatermarksOpts{
+			RESTConfig:    cfg,
+			Registrations: registrations,
+			Namespace:     *checkpointNamespace,
+			Name:          *checkpointConfigMap,
+			Interval:      *checkpointInterval,
+		})
+		if err != nil {
+			klog.Fatalf("unable to create the delivery watermarks: %s", err.Error())
+		}
+
+		// the leader loads them once elected
+		if !*leaderElect {
+			loadWatermarks(watermarks)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			watermarks.Run(stop)
+		}()
+	}
+
+	// setup the store of undelivered notifications
+	deadLetter, err := deadletter.NewStore(deadletter.StoreOpts{
+		Size: *deadLetterSize,
+		Path: *deadLetterFile,
+	})
+	if err != nil {
+		klog.Fatalf("unable to create the dead-letter store: %s", err.Error())
+	}
+
+	// setup the metadata cache of the resolved objects
+	var objectCache *objects.MetadataCache
+	if *objectCacheTTL > 0 || *objectInformers {
+		objectCache, err = objects.NewMetadataCache(objects.MetadataCacheOpts{
+			RESTConfig:     cfg,
+			TTL:            *objectCacheTTL,
+			Size:           *objectCacheSize,
+			Informers:      *objectInformers,
+			InformerKinds:  objects.ParsePatterns(*objectInformersKinds),
+			MaxInformers:   *objectInformersMax,
+			ResyncInterval: *resyncInterval,
+		})
+		if err != nil {
+			klog.Fatalf("unable to create the objects metadata cache: %s", err.Error())
+		}
+		go objectCache.Run(stop)
+	}
+
+	// setup the lookup of the namespaces labels matched by the registrations
+	namespaceLabels, err := router.NewNamespaceLabels(router.NamespaceLabelsOpts{
+		Client:         clientSet,
+		ResyncInterval: *resyncInterval,
+	})
+	if err != nil {
+		klog.Fatalf("unable to create the namespaces labels lookup: %s", err.Error())
+	}
+	go namespaceLabels.Run(stop)
+
+	// setup the rescheduling of the failed notifications, the pending ones
+	// are cancelled on shutdown (dead-lettered without watermarks)
+	retrier := router.NewRetrier(router.RetrierOpts{Queue: q})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		retrier.Run(stop)
+	}()
 
 	handler, err := router.NewPusher(router.PusherOpts{
-		RESTConfig: cfg,
-		Queue:      q,
-		Verbose:    *debug,
-		Insecure:   *insecure,
+		RESTConfig:    cfg,
+		Registrations: registrations,
+		Queue:         q,
+		Verbose:       *debug,
+		Insecure:      *insecure,
+		Backoff: wait.Backoff{
+			Duration: *deliveryBackoff,
+			Factor:   2.0,
+			Jitter:   0.2,
+			Steps:    *deliveryRetries,
+			Cap:      *deliveryBackoffMax,
+		},
+		Retrier:    retrier,
+		DeadLetter: deadLetter,
+		Recorder:   recorder,
+		Refs:       refsResolver,
+		Watermarks: watermarks,
+
+		OwnerReferencesDepth: *ownerReferencesDepth,
+		CorrelationKeys:      corrKeys,
+		StampEvents:          *stampEvents,
+		ObjectCache:          objectCache,
+		Namespaces:           namespaceLabels,
 	})
 	if err != nil {
 		klog.Fatalf("unable to create the event notifier: %s", err.Error())
 	}
 
+	// the events field selector, narrowed to the registrations filters if enabled
+	eventsFieldSelector := func() fields.Selector {
+		if !*fieldSelectorFromRegistrations {
+			return fieldSel
+		}
+		return fields.AndSelectors(fieldSel, registrations.FieldSelector())
+	}
+
+	go acceptor.Run(stop)
+
 	eventRouter := router.NewEventRouter(router.EventRouterOpts{
-		RESTClient:     clientSet.CoreV1().RESTClient(),
-		Handler:        handler,
-		Namespace:      *namespace,
-		ThrottlePeriod: *throttlePeriod,
+		RESTClient:        clientSet.CoreV1().RESTClient(),
+		EventsRESTClient:  clientSet.EventsV1().RESTClient(),
+		EventsAPI:         api,
+		Handler:           handler,
+		Namespaces:        router.ParseNamespaces(*namespace),
+		NamespaceSelector: nsSelector,
+		FieldSelector:     eventsFieldSelector(),
+		Acceptor:          acceptor,
+		ResyncInterval:    *resyncInterval,
+		ThrottlePeriod:    *throttlePeriod,
+		Standby:           *leaderElect,
+		StampEvents:       *stampEvents,
 	})
+	checker.AddReadinessCheck("events", synced(eventRouter.HasSynced))
 
-	stop := sigHandler()
+	if *fieldSelectorFromRegistrations {
+		registrations.OnChange(func() {
+			eventRouter.SetFieldSelector(eventsFieldSelector())
+		})
+		// the registrations changed since the router creation
+		eventRouter.SetFieldSelector(eventsFieldSelector())
+	}
 
-	// Startup the EventRouter
-	var wg sync.WaitGroup
+	// setup the leader election, standby replicas keep their events cache warm
+	if *leaderElect {
+		elector, err := leader.NewElector(leader.ElectorOpts{
+			RESTConfig:    cfg,
+			Namespace:     *leaderElectNamespace,
+			Name:          *leaderElectLeaseName,
+			Identity:      env.String("POD_NAME", ""),
+			LeaseDuration: *leaderElectLeaseDuration,
+			RenewDeadline: *leaderElectRenewDeadline,
+			RetryPeriod:   *leaderElectRetryPeriod,
+			OnStartedLeading: func() {
+				// with the watermarks all the cached events not yet handled are replayed,
+				// otherwise the ones possibly missed while the previous leader was gone
+				// and its lease not yet expired
+				if watermarks != nil {
+					loadWatermarks(watermarks)
+					eventRouter.Activate(time.Time{})
+					return
+				}
+				eventRouter.Activate(time.Now().Add(-(*leaderElectLeaseDuration + *leaderElectRenewDeadline)))
+			},
+			OnStoppedLeading: eventRouter.Deactivate,
+		})
+		if err != nil {
+			klog.Fatalf("unable to create the leader elector: %s", err.Error())
+		}
+
+		// on shutdown the lease is released, waiting for it lets a standby take over at once
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
+			if !cache.WaitForCacheSync(stop, eventRouter.HasSynced) {
+				return
+			}
+			elector.Run(stop)
+		}()
+	}
+
+	// Startup the EventRouter
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -116,17 +458,57 @@ func main() {
 			"resyncInterval", *resyncInterval,
 			"throttlePeriod", *throttlePeriod,
 			"namespace", *namespace,
+			"namespaceSelector", *namespaceSelector,
+			"fieldSelector", eventsFieldSelector().String(),
+			"eventsAPI", api,
 			"queueMaxCapacity", *queueMaxCapacity,
-			"queueWorkerThreads", *queueWorkerThreads)
+			"queueWorkerThreads", *queueWorkerThreads,
+			"deliveryRetries", *deliveryRetries,
+			"deadLetterFile", *deadLetterFile,
+			"refsNamespaces", *refsNamespaces,
+			"metricsAddress", *metricsAddress,
+			"healthAddress", *healthAddress,
+			"leaderElect", *leaderElect,
+			"checkpointConfigMap", *checkpointConfigMap,
+			"stampEvents", *stampEvents)
 
 		eventRouter.Run(stop)
 	}()
 
 	wg.Wait()
+
+	// os.Exit skips the deferred calls: waits for the notifications
+	// in flight and flushes the undelivered ones before exiting
+	q.Terminate()
+	if err := deadLetter.Close(); err != nil {
+		klog.ErrorS(err, "unable to close the dead-letter store")
+	}
+
 	klog.Infof("%s done", serviceName)
 	os.Exit(1)
 }
 
+// loadWatermarks reads the checkpointed watermarks, on failure
+// only the events received from now on are notified
+func loadWatermarks(wm *router.Watermarks) {
+	ctx, cncl := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cncl()
+
+	if err := wm.Load(ctx); err != nil {
+		klog.ErrorS(err, "unable to load the delivery watermarks")
+	}
+}
+
+// synced adapts an informer HasSynced func to a readiness check
+func synced(fn cache.InformerSynced) health.Check {
+	return func() error {
+		if !fn() {
+			return fmt.Errorf("cache not synced")
+		}
+		return nil
+	}
+}
+
 // setup a signal hander to gracefully exit
 func sigHandler() <-chan struct{} {
 	stop := make(chan struct{})
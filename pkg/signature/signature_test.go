@@ -0,0 +1,86 @@
+package signature
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	key := []byte("s3cr3t")
+	body := []byte(`{"reason":"CannotCreateExternalResource"}`)
+
+	h := http.Header{}
+	SetHeaders(h, key, time.Now(), body)
+
+	if err := Verify(key, h, body, time.Minute); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+
+	if err := Verify([]byte("other"), h, body, time.Minute); !errors.Is(err, ErrMismatch) {
+		t.Errorf("expected mismatch with wrong key, got %v", err)
+	}
+
+	if err := Verify(key, h, []byte(`{}`), time.Minute); !errors.Is(err, ErrMismatch) {
+		t.Errorf("expected mismatch with tampered body, got %v", err)
+	}
+}
+
+func TestVerifyTimestamp(t *testing.T) {
+	key := []byte("s3cr3t")
+	body := []byte(`{}`)
+
+	h := http.Header{}
+	SetHeaders(h, key, time.Now().Add(-time.Hour), body)
+
+	if err := Verify(key, h, body, time.Minute); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected expired signature, got %v", err)
+	}
+
+	if err := Verify(key, h, body, 0); err != nil {
+		t.Errorf("expected valid signature without tolerance, got %v", err)
+	}
+
+	h.Set(HeaderTimestamp, "yesterday")
+	if err := Verify(key, h, body, 0); !errors.Is(err, ErrInvalidTimestamp) {
+		t.Errorf("expected invalid timestamp, got %v", err)
+	}
+
+	if err := Verify(key, http.Header{}, body, 0); !errors.Is(err, ErrMissingHeaders) {
+		t.Errorf("expected missing headers, got %v", err)
+	}
+}
+
+func TestSignKnownValue(t *testing.T) {
+	got := Sign([]byte("key"), time.Unix(1660835529, 0), []byte("body"))
+	want := "v1=2331be6a30bdffe9073cef5f4f78851f956770a5926fb1caf4aca15d4d74181f"
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	key := []byte("s3cr3t")
+	body := []byte(`{"reason":"Test"}`)
+
+	req := httptest.NewRequest(http.MethodPost, "/handle", bytes.NewReader(body))
+	SetHeaders(req.Header, key, time.Now(), body)
+
+	got, err := VerifyRequest(key, req, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, body) {
+		t.Errorf("unexpected body: %s", got)
+	}
+
+	again, _ := io.ReadAll(req.Body)
+	if !bytes.Equal(again, body) {
+		t.Errorf("expected restored body, got %s", again)
+	}
+}